@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/surol/speedtest-cli/speedtest"
+)
+
+// Prometheus exporter running speed tests on scrape.
+// Test results are cached for the configured duration, so that frequent scrapes do not saturate the link.
+// Only one test runs at a time. When the cached result expires, the test runs in the background,
+// and scrapes are served the last result meanwhile. Only the very first scrape waits for the test to complete.
+type exporter struct {
+	opts     *speedtest.Opts
+	test     func() (*speedtest.Result, error)
+	mutex    sync.Mutex
+	running  chan struct{} // Closed when the running test completes. nil when no test is running
+	result   *speedtest.Result
+	duration time.Duration
+	lastRun  time.Time
+	success  bool
+	runs     uint64
+	failures uint64
+}
+
+func newExporter(opts *speedtest.Opts) *exporter {
+	exporter := &exporter{opts: opts}
+	exporter.test = exporter.runTest
+	return exporter
+}
+
+func serveMetrics(opts *speedtest.Opts) error {
+	exporter := newExporter(opts)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+
+	log.Printf("Serving metrics at %s/metrics\n", opts.Listen)
+
+	return http.ListenAndServe(opts.Listen, mux)
+}
+
+func (exporter *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	exporter.mutex.Lock()
+
+	if exporter.lastRun.IsZero() || time.Since(exporter.lastRun) >= exporter.opts.MetricsTTL {
+		done := exporter.refresh()
+		if exporter.runs == 0 {
+			// Nothing to serve yet
+			exporter.mutex.Unlock()
+			<-done
+			exporter.mutex.Lock()
+		}
+	}
+
+	defer exporter.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	exporter.write(w)
+}
+
+// Starts the test in the background, unless it is running already.
+// Returns a channel closed when the test completes.
+// Should be called with the mutex locked.
+func (exporter *exporter) refresh() <-chan struct{} {
+	if exporter.running == nil {
+		exporter.running = make(chan struct{})
+		go exporter.run(exporter.running)
+	}
+	return exporter.running
+}
+
+func (exporter *exporter) run(done chan struct{}) {
+	start := time.Now()
+	result, err := exporter.test()
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	defer close(done)
+
+	exporter.running = nil
+	exporter.lastRun = time.Now()
+	exporter.runs++
+	exporter.success = err == nil
+	if err != nil {
+		exporter.failures++
+		log.Printf("Speed test failed: %v\n", err)
+		return
+	}
+
+	exporter.result = result
+	exporter.duration = time.Since(start)
+}
+
+func (exporter *exporter) runTest() (*speedtest.Result, error) {
+	client, err := speedtest.NewClient(exporter.opts)
+	if err != nil {
+		return nil, err
+	}
+	return runTest(context.Background(), exporter.opts, client)
+}
+
+func (exporter *exporter) write(w io.Writer) {
+	writeMetric(w, "speedtest_runs_total", "counter", "Total number of speed test runs.", "", float64(exporter.runs))
+	writeMetric(w, "speedtest_failures_total", "counter", "Total number of failed speed test runs.", "", float64(exporter.failures))
+
+	up := 0.0
+	if exporter.success {
+		up = 1
+	}
+	writeMetric(w, "speedtest_up", "gauge", "Whether the last speed test run succeeded.", "", up)
+
+	result := exporter.result
+	if result == nil {
+		return
+	}
+
+	isp := ""
+	if result.Client != nil {
+		isp = result.Client.ISP
+	}
+	labels := fmt.Sprintf(
+		`server_id="%d",sponsor="%s",isp="%s"`,
+		result.Server.ID,
+		escapeLabel(result.Server.Sponsor),
+		escapeLabel(isp))
+
+	writeMetric(w, "speedtest_download_bits_per_second", "gauge", "Download speed in bits per second.",
+		labels, result.Download.BitsPerSecond)
+	writeMetric(w, "speedtest_upload_bits_per_second", "gauge", "Upload speed in bits per second.",
+		labels, result.Upload.BitsPerSecond)
+	writeMetric(w, "speedtest_download_wire_bits_per_second", "gauge",
+		"Download throughput over network connections, including protocol overhead, in bits per second.",
+		labels, result.Download.WireBitsPerSecond)
+	writeMetric(w, "speedtest_upload_wire_bits_per_second", "gauge",
+		"Upload throughput over network connections, including protocol overhead, in bits per second.",
+		labels, result.Upload.WireBitsPerSecond)
+	writeMetric(w, "speedtest_latency_seconds", "gauge", "Server latency in seconds.",
+		labels, result.Server.LatencyMs/1000)
+	writeMetric(w, "speedtest_jitter_seconds", "gauge", "Server latency jitter in seconds.",
+		labels, result.Server.Latency.JitterMs/1000)
+	if result.Server.Distance != nil {
+		writeMetric(w, "speedtest_server_distance_kilometers", "gauge", "Distance to the server in kilometers.",
+			labels, *result.Server.Distance)
+	}
+	writeMetric(w, "speedtest_test_duration_seconds", "gauge", "Duration of the last successful speed test in seconds.",
+		labels, exporter.duration.Seconds())
+	writeMetric(w, "speedtest_last_success_timestamp_seconds", "gauge", "Time of the last successful speed test.",
+		labels, float64(result.Timestamp.UnixNano())/float64(time.Second))
+}
+
+func writeMetric(w io.Writer, name string, kind string, help string, labels string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	if len(labels) != 0 {
+		fmt.Fprintf(w, "%s{%s} %g\n", name, labels, value)
+	} else {
+		fmt.Fprintf(w, "%s %g\n", name, value)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/surol/speedtest-cli/speedtest"
+)
+
+func TestExporter(t *testing.T) {
+	tests := make(chan error)
+	release := make(chan struct{})
+	speed := 0.0
+
+	exporter := newExporter(&speedtest.Opts{MetricsTTL: time.Hour})
+	exporter.test = func() (*speedtest.Result, error) {
+		<-release
+		speed += 1000
+		if err := <-tests; err != nil {
+			return nil, err
+		}
+		return &speedtest.Result{
+			Timestamp: time.Now(),
+			Server:    speedtest.ServerResult{ID: 1234, Sponsor: `"Sponsor"`},
+			Download:  &speedtest.TransferResult{BitsPerSecond: speed},
+			Upload:    &speedtest.TransferResult{BitsPerSecond: speed / 2},
+		}, nil
+	}
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		return recorder.Body.String()
+	}
+	expect := func(name string, metrics string, want ...string) {
+		for _, line := range want {
+			if !strings.Contains(metrics, line+"\n") {
+				t.Errorf("%s: metric not found: %s\n%s", name, line, metrics)
+			}
+		}
+	}
+	wait := func() {
+		exporter.mutex.Lock()
+		done := exporter.running
+		exporter.mutex.Unlock()
+		if done != nil {
+			<-done
+		}
+	}
+	labels := `{server_id="1234",sponsor="\"Sponsor\"",isp=""}`
+
+	// The first scrape waits for the test
+	first := make(chan string)
+	go func() { first <- scrape() }()
+	release <- struct{}{}
+	tests <- nil
+	expect("first scrape", <-first,
+		"speedtest_runs_total 1",
+		"speedtest_up 1",
+		"speedtest_download_bits_per_second"+labels+" 1000",
+		"speedtest_upload_bits_per_second"+labels+" 500")
+
+	// Cached result is served without running the test
+	expect("cached scrape", scrape(), "speedtest_runs_total 1")
+
+	// Expired result is served while the test runs in the background
+	exporter.opts.MetricsTTL = 0
+	expect("expired scrape", scrape(),
+		"speedtest_runs_total 1",
+		"speedtest_download_bits_per_second"+labels+" 1000")
+	release <- struct{}{}
+	expect("scrape during refresh", scrape(),
+		"speedtest_runs_total 1",
+		"speedtest_download_bits_per_second"+labels+" 1000")
+	tests <- nil
+	wait()
+
+	// Failed test keeps the last result
+	go scrape()
+	release <- struct{}{}
+	tests <- errors.New("failed")
+	wait()
+	exporter.opts.MetricsTTL = time.Hour
+	expect("scrape after failure", scrape(),
+		"speedtest_runs_total 3",
+		"speedtest_failures_total 1",
+		"speedtest_up 0",
+		"speedtest_download_bits_per_second"+labels+" 2000")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/surol/speedtest-cli/speedtest"
+)
+
+// Whether to test against multiple servers.
+func matrixMode(opts *speedtest.Opts) bool {
+	return len(opts.Servers) > 1 || opts.Top > 0
+}
+
+// Runs the tests against each of the specified servers.
+// When interrupted, returns the partial result along with the context error.
+func runMatrix(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) (*speedtest.MatrixResult, error) {
+	switch {
+	case len(opts.Mini) != 0:
+		return nil, usageError("-mini option can not be used with multiple servers")
+	case opts.Share:
+		return nil, usageError("-share option can not be used with multiple servers")
+	}
+
+	config, err := loadConfig(ctx, opts, client)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := matrixServers(ctx, opts, client)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := opts.Profile
+	if profile == nil {
+		profile = speedtest.StandardProfile
+	}
+
+	var times speedtest.ConfigTimes
+	if config != nil && !opts.FixedSizes {
+		times = config.Times
+	}
+
+	matrix, err := servers.TestMatrix(ctx, latencyMode(opts), times, profile)
+	result := speedtest.NewMatrixResult(config, matrix)
+	if textOutput(opts) {
+		reportMatrix(opts, result)
+	}
+
+	return result, err
+}
+
+func matrixServers(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) (*speedtest.Servers, error) {
+	all, err := client.AllServersContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load server list: %w", err)
+	}
+
+	if opts.Top > 0 {
+		servers := all.Filter(opts.Filter)
+		if servers.Len() == 0 {
+			return nil, speedtest.NoServersError
+		}
+		if servers.Len() > opts.Top {
+			servers.List = servers.List[:opts.Top]
+		}
+		return servers, nil
+	}
+
+	servers := &speedtest.Servers{}
+	for _, id := range opts.Servers {
+		server := all.Find(id)
+		if server == nil {
+			return nil, &speedtest.ServerNotFoundError{ID: id}
+		}
+		servers.List = append(servers.List, server)
+	}
+	return servers, nil
+}
+
+func reportMatrix(opts *speedtest.Opts, result *speedtest.MatrixResult) {
+	unit, scale := "Mib/s", float64(1 << 20)
+	if opts.SpeedInBytes {
+		unit, scale = "MiB/s", float64(8 << 20)
+	}
+	speed := func(transfer *speedtest.TransferResult) string {
+		if transfer == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f %s", transfer.BitsPerSecond / scale, unit)
+	}
+
+	fmt.Println()
+	fmt.Printf("%-50s %12s %14s %14s\n", "Server", "Ping", "Download", "Upload")
+	for _, server := range result.Servers {
+		fmt.Printf("%-50s %12s %14s %14s\n",
+			fmt.Sprintf("%d: %s (%s)", server.Server.ID, server.Server.Sponsor, server.Server.Name),
+			fmt.Sprintf("%.2f ms", server.Server.LatencyMs),
+			speed(server.Download),
+			speed(server.Upload))
+	}
+
+	aggregate := result.Aggregate
+	fmt.Printf("%-50s %12s %14s %14s\n",
+		"Best",
+		fmt.Sprintf("%.2f ms", aggregate.Latency.Best),
+		fmt.Sprintf("%.2f %s", aggregate.Download.Best / scale, unit),
+		fmt.Sprintf("%.2f %s", aggregate.Upload.Best / scale, unit))
+	fmt.Printf("%-50s %12s %14s %14s\n",
+		"Median",
+		fmt.Sprintf("%.2f ms", aggregate.Latency.Median),
+		fmt.Sprintf("%.2f %s", aggregate.Download.Median / scale, unit),
+		fmt.Sprintf("%.2f %s", aggregate.Upload.Median / scale, unit))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/surol/speedtest-cli/speedtest"
+)
+
+// Runs the test against multiple servers simultaneously.
+// When interrupted, returns the partial result along with the context error.
+func runParallel(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) (*speedtest.Result, error) {
+	switch {
+	case opts.Server != 0:
+		return nil, usageError("-server option can not be used with -parallel")
+	case len(opts.Mini) != 0:
+		return nil, usageError("-mini option can not be used with -parallel")
+	case opts.Share:
+		return nil, usageError("-share option can not be used with -parallel")
+	case opts.Bufferbloat:
+		return nil, usageError("-bufferbloat option can not be used with -parallel")
+	}
+
+	config, err := loadConfig(ctx, opts, client)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := client.ClosestServersContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load server list: %w", err)
+	}
+	servers, err = servers.MeasureLatenciesMode(
+		ctx,
+		latencyMode(opts),
+		speedtest.DefaultLatencyMeasureTimes,
+		speedtest.DefaultErrorLatency)
+	if err != nil {
+		return nil, err
+	}
+	best := &speedtest.Servers{List: servers.List}
+	if best.Len() > opts.Parallel {
+		best.List = best.List[:opts.Parallel]
+	}
+	for _, server := range best.List {
+		client.Log("Hosted by %s (%s) [%s]: %d ms\n",
+			server.Sponsor,
+			server.Name,
+			server.DistanceString(),
+			server.Latency / time.Millisecond)
+	}
+
+	profile := opts.Profile
+	if profile == nil {
+		profile = speedtest.StandardProfile
+	}
+
+	result := speedtest.NewResult(config, best.First())
+
+	download := best.DownloadParallel(ctx, profile)
+	result.Download = speedtest.NewTransferResult(download, nil)
+	reportSpeed(opts, "Download", result.Download)
+	reportWire(opts, result.Download)
+	reportShares(opts, result.Download)
+	if download.Err != nil {
+		return result, download.Err
+	}
+
+	upload := best.UploadParallel(ctx, profile)
+	result.Upload = speedtest.NewTransferResult(upload, nil)
+	reportSpeed(opts, "Upload", result.Upload)
+	reportWire(opts, result.Upload)
+	reportShares(opts, result.Upload)
+	if upload.Err != nil {
+		return result, upload.Err
+	}
+
+	return result, nil
+}
+
+func reportShares(opts *speedtest.Opts, transfer *speedtest.TransferResult) {
+	if !textOutput(opts) || opts.Quiet {
+		return
+	}
+	for _, server := range transfer.Servers {
+		if opts.SpeedInBytes {
+			fmt.Printf("  %d: %s (%s): %.2f MiB/s, %.1f%%\n",
+				server.ID, server.Sponsor, server.Name, server.BitsPerSecond / (8 << 20), server.Share * 100)
+		} else {
+			fmt.Printf("  %d: %s (%s): %.2f Mib/s, %.1f%%\n",
+				server.ID, server.Sponsor, server.Name, server.BitsPerSecond / (1 << 20), server.Share * 100)
+		}
+	}
+}
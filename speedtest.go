@@ -2,8 +2,12 @@ package main
 
 import (
 	"github.com/surol/speedtest-cli/speedtest"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"flag"
 	"log"
 	"time"
@@ -30,74 +34,442 @@ func main() {
 		return
 	}
 
-	client := speedtest.NewClient(opts)
+	if opts.CSV || opts.CSVHeader {
+		delimiter := []rune(opts.CSVDelimiter)
+		if len(delimiter) != 1 {
+			log.Printf("Invalid CSV delimiter: %q\n", opts.CSVDelimiter)
+			os.Exit(exitUsage)
+		}
+		if opts.CSVHeader {
+			if err := speedtest.WriteCSVHeader(os.Stdout, delimiter[0]); err != nil {
+				fatal(err)
+			}
+			return
+		}
+	}
+
+	if err := checkOptions(opts); err != nil {
+		fatal(err)
+	}
+
+	if opts.JSON || opts.CSV {
+		opts.Quiet = true
+	}
+
+	if len(opts.Listen) != 0 {
+		opts.Quiet = true
+		fatal(serveMetrics(opts))
+	}
+
+	if !opts.Quiet {
+		opts.Progress = progressPrinter()
+	}
+
+	client, err := speedtest.NewClient(opts)
+	if err != nil {
+		fatal(err)
+	}
 
 	if opts.List {
 		servers, err := client.AllServers()
 		if err != nil {
-			log.Fatalf("Failed to load server list: %v\n", err)
+			fatal(fmt.Errorf("Failed to load server list: %w", err))
+		}
+		fmt.Println(servers.Filter(opts.Filter))
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if matrixMode(opts) {
+		result, err := runMatrix(ctx, opts, client)
+		if result != nil {
+			writeResult(opts, result)
+		}
+		if err != nil {
+			fatal(err)
 		}
-		fmt.Println(servers)
 		return
 	}
 
-	config, err := client.Config()
+	run := runTest
+	if opts.Parallel > 1 {
+		run = runParallel
+	}
+	result, err := run(ctx, opts, client)
+	if result != nil {
+		writeResult(opts, result)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// Exit codes.
+const (
+	exitFailure     = 1
+	exitUsage       = 2
+	exitConfig      = 3
+	exitNoServers   = 4
+	exitServerURL   = 5
+	exitHTTPStatus  = 6
+	exitInterrupted = 130
+)
+
+// Invalid combination of command line options.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// Rejects the options conflicting with each other regardless of the test being run.
+func checkOptions(opts *speedtest.Opts) error {
+	switch {
+	case opts.Parallel > 1 && matrixMode(opts):
+		return usageError("-parallel option can not be used with -top option or multiple servers")
+	case len(opts.Listen) != 0 && (opts.Parallel > 1 || matrixMode(opts)):
+		return usageError("-listen option can not be used with -parallel, -top options or multiple servers")
+	}
+	return nil
+}
+
+// Logs the error and exits with the code corresponding to the error class.
+func fatal(err error) {
+	log.Println(err)
+	os.Exit(exitCode(err))
+}
+
+func exitCode(err error) int {
+	var bindAddressError *speedtest.BindAddressError
+	var configError *speedtest.ConfigError
+	var serverURLError *speedtest.ServerURLError
+	var httpStatusError *speedtest.HTTPStatusError
+	var usage usageError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, &bindAddressError), errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &configError):
+		return exitConfig
+	case errors.Is(err, speedtest.NoServersError):
+		return exitNoServers
+	case errors.As(err, &serverURLError):
+		return exitServerURL
+	case errors.As(err, &httpStatusError):
+		return exitHTTPStatus
+	}
+	return exitFailure
+}
+
+// Runs the test. When interrupted, returns the partial result along with the context error.
+func runTest(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) (*speedtest.Result, error) {
+	var config *speedtest.Config
+	if len(opts.Mini) == 0 {
+		var err error
+		config, err = loadConfig(ctx, opts, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	server, err := selectServer(ctx, opts, client)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := opts.Profile
+	if profile == nil {
+		profile = speedtest.StandardProfile
+	}
+	if config != nil && !opts.FixedSizes {
+		profile, err = server.SelectPayloadSizes(ctx, config.Times, profile)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			client.Log("Failed to estimate line speed, using all payload sizes: %v", err)
+		}
+	}
+
+	result := speedtest.NewResult(config, server)
+
+	download := server.DownloadProfile(ctx, profile)
+	result.Download = speedtest.NewTransferResult(download, server.IdleLatency())
+	reportSpeed(opts, "Download", result.Download)
+	reportWire(opts, result.Download)
+	reportLoadedLatency(opts, result.Download)
+	if download.Err != nil {
+		return result, download.Err
+	}
+
+	upload := server.UploadProfile(ctx, profile)
+	result.Upload = speedtest.NewTransferResult(upload, server.IdleLatency())
+	reportSpeed(opts, "Upload", result.Upload)
+	reportWire(opts, result.Upload)
+	reportLoadedLatency(opts, result.Upload)
+	if upload.Err != nil {
+		return result, upload.Err
+	}
+
+	if opts.Verbose && textOutput(opts) {
+		fmt.Println("Request phases, mean / max:")
+		reportPhases("Latency", result.Server.Phases)
+		reportPhases("Download", result.Download.Phases)
+		reportPhases("Upload", result.Upload.Phases)
+	}
+
+	if opts.Share {
+		share, err := speedtest.ShareContext(ctx, client, opts.ShareURL, result)
+		if err != nil {
+			return result, err
+		}
+		result.Share = share
+		if textOutput(opts) {
+			fmt.Printf("Share results: %s\n", share)
+		}
+	}
+
+	return result, nil
+}
+
+// Retrieves speedtest.net configuration.
+// Tolerates the failure when server lists are specified, so that the test can run in isolated networks.
+func loadConfig(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) (*speedtest.Config, error) {
+	config, err := client.ConfigContext(ctx)
 	if err != nil {
-		log.Fatal(err)
+		if len(opts.ServerSources) == 0 || ctx.Err() != nil {
+			return nil, err
+		}
+		client.Log("%v. Using specified server lists only", err)
+		return nil, nil
 	}
 
 	client.Log("Testing from %s (%s)...\n", config.Client.ISP, config.Client.IP)
 
-	server := selectServer(opts, client);
+	return config, nil
+}
+
+// Test result in machine-readable formats.
+type output interface {
+	WriteJSON(out io.Writer) error
+	WriteCSV(out io.Writer, delimiter rune) error
+}
+
+func writeResult(opts *speedtest.Opts, result output) {
+	var err error
+	switch {
+	case opts.JSON:
+		err = result.WriteJSON(os.Stdout)
+	case opts.CSV:
+		err = result.WriteCSV(os.Stdout, []rune(opts.CSVDelimiter)[0])
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// Prints the test header and a dot per each started request.
+func progressPrinter() speedtest.ProgressFunc {
+	running := false
+	requests := 0
+	return func(progress speedtest.Progress) {
+		if !running {
+			running = true
+			requests = 0
+			fmt.Printf("Testing %s speed: ", progress.Phase)
+		}
+		for ; requests < progress.Requests; requests++ {
+			fmt.Print(".")
+		}
+		if progress.Done {
+			running = false
+			fmt.Println()
+		}
+	}
+}
 
-	downloadSpeed := server.DownloadSpeed()
-	reportSpeed(opts, "Download", downloadSpeed)
+// Whether to report results in human-readable form.
+func textOutput(opts *speedtest.Opts) bool {
+	return !opts.JSON && !opts.CSV && len(opts.Listen) == 0
+}
 
-	uploadSpeed := server.UploadSpeed()
-	reportSpeed(opts, "Upload", uploadSpeed)
+func reportSpeed(opts *speedtest.Opts, prefix string, transfer *speedtest.TransferResult) {
+	speed := transfer.BytesPerSecond
+	stable := transfer.Throughput.Stable / 8
+	switch {
+	case !textOutput(opts):
+		return
+	case opts.SpeedInBytes:
+		fmt.Printf("%s: %.2f MiB/s (stable: %.2f MiB/s)\n", prefix, speed / (1 << 20), stable / (1 << 20))
+	default:
+		fmt.Printf("%s: %.2f Mib/s (stable: %.2f Mib/s)\n", prefix, speed / (1 << 17), stable / (1 << 17))
+	}
 }
 
-func reportSpeed(opts *speedtest.Opts, prefix string, speed int) {
+func reportWire(opts *speedtest.Opts, transfer *speedtest.TransferResult) {
+	if !textOutput(opts) || opts.Quiet {
+		return
+	}
 	if opts.SpeedInBytes {
-		fmt.Printf("%s: %.2f MiB/s\n", prefix, float64(speed) / (1 << 20))
+		fmt.Printf("Wire: %.2f MiB/s, protocol overhead: %.1f%%\n",
+			transfer.WireBitsPerSecond / 8 / (1 << 20), transfer.OverheadPercent)
 	} else {
-		fmt.Printf("%s: %.2f Mib/s\n", prefix, float64(speed) / (1 << 17))
+		fmt.Printf("Wire: %.2f Mib/s, protocol overhead: %.1f%%\n",
+			transfer.WireBitsPerSecond / (1 << 20), transfer.OverheadPercent)
 	}
 }
 
-func selectServer(opts *speedtest.Opts, client speedtest.Client) (selected *speedtest.Server) {
-	if opts.Server != 0 {
-		servers, err := client.AllServers()
+func reportPhases(prefix string, phases *speedtest.PhasesResult) {
+	if phases == nil {
+		return
+	}
+	phase := func(result speedtest.PhaseResult) string {
+		if result.Count == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.2f / %.2f ms", result.MeanMs, result.MaxMs)
+	}
+	fmt.Printf("  %s: DNS %s, connect %s, TLS %s, first byte %s (%d requests, %d reused connections)\n",
+		prefix,
+		phase(phases.DNS),
+		phase(phases.Connect),
+		phase(phases.TLS),
+		phase(phases.TTFB),
+		phases.Requests,
+		phases.Reused)
+}
+
+func reportLoadedLatency(opts *speedtest.Opts, transfer *speedtest.TransferResult) {
+	if !textOutput(opts) || transfer.LoadedLatency == nil {
+		return
+	}
+	fmt.Printf("Loaded latency: %.2f ms, jitter: %.2f ms, bufferbloat grade: %s\n",
+		transfer.LoadedLatency.MedianMs,
+		transfer.LoadedLatency.JitterMs,
+		transfer.BufferbloatGrade)
+}
+
+func selectServer(
+	ctx context.Context,
+	opts *speedtest.Opts,
+	client speedtest.Client) (selected *speedtest.Server, err error) {
+	var servers *speedtest.Servers
+
+	if len(opts.Mini) != 0 {
+		selected, err = speedtest.NewMiniServerContext(ctx, client, opts.Mini)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to connect to Speedtest Mini server: %w", err)
+		}
+		_, err = selected.MeasureLatencyMode(
+			ctx,
+			latencyMode(opts),
+			speedtest.DefaultLatencyMeasureTimes,
+			speedtest.DefaultErrorLatency)
+	} else if opts.Server != 0 {
+		servers, err = client.AllServersContext(ctx)
 		if err != nil {
-			log.Fatal("Failed to load server list: %v\n", err)
-			return nil
+			return nil, fmt.Errorf("Failed to load server list: %w", err)
 		}
 		selected = servers.Find(opts.Server)
 		if selected == nil {
-			log.Fatalf("Server not found: %d\n", opts.Server)
-			return nil
+			return nil, &speedtest.ServerNotFoundError{ID: opts.Server}
 		}
-		selected.MeasureLatency(speedtest.DefaultLatencyMeasureTimes, speedtest.DefaultErrorLatency)
+		_, err = selected.MeasureLatencyMode(
+			ctx,
+			latencyMode(opts),
+			speedtest.DefaultLatencyMeasureTimes,
+			speedtest.DefaultErrorLatency)
 	} else {
-		servers, err := client.ClosestServers()
+		servers, err = client.ClosestServersContext(ctx)
 		if err != nil {
-			log.Fatal("Failed to load server list: %v\n", err)
-			return nil
+			return nil, fmt.Errorf("Failed to load server list: %w", err)
 		}
-		selected = servers.MeasureLatencies(
+		servers, err = servers.MeasureLatenciesMode(
+			ctx,
+			latencyMode(opts),
 			speedtest.DefaultLatencyMeasureTimes,
-			speedtest.DefaultErrorLatency).First()
+			speedtest.DefaultErrorLatency)
+		selected = servers.First()
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	if opts.Quiet {
 		log.Printf("Ping: %d ms\n", selected.Latency / time.Millisecond)
+		log.Printf("Jitter: %.2f ms\n", float64(selected.LatencyStats.Jitter) / float64(time.Millisecond))
 	} else {
-		client.Log("Hosted by %s (%s) [%.2f km]: %d ms\n",
+		client.Log("Hosted by %s (%s) [%s]: %d ms, jitter: %.2f ms\n",
 			selected.Sponsor,
 			selected.Name,
-			selected.Distance,
-			selected.Latency / time.Millisecond)
+			selected.DistanceString(),
+			selected.Latency / time.Millisecond,
+			float64(selected.LatencyStats.Jitter) / float64(time.Millisecond))
+	}
+
+	if err := measureLatencyModes(ctx, opts, selected); err != nil {
+		return nil, err
+	}
+
+	return selected, nil
+}
+
+// Returns the latency mode used for server selection.
+func latencyMode(opts *speedtest.Opts) speedtest.LatencyMode {
+	if len(opts.LatencyModes) == 0 {
+		return speedtest.LatencyWarm
+	}
+	return opts.LatencyModes[0]
+}
+
+// Measures the server latency in the modes other than the one used for server selection,
+// keeping the latency used for server selection intact.
+func measureLatencyModes(ctx context.Context, opts *speedtest.Opts, server *speedtest.Server) error {
+	modes := extraLatencyModes(opts)
+	if len(modes) == 0 {
+		return nil
+	}
+	latency, stats, phases := server.Latency, server.LatencyStats, server.LatencyPhases
+	defer func() {
+		server.Latency, server.LatencyStats, server.LatencyPhases = latency, stats, phases
+	}()
+
+	for _, mode := range modes {
+		if _, err := server.MeasureLatencyMode(
+			ctx,
+			mode,
+			speedtest.DefaultLatencyMeasureTimes,
+			speedtest.DefaultErrorLatency); err != nil {
+			return err
+		}
+		if textOutput(opts) {
+			fmt.Printf("Latency (%s): %.2f ms, jitter: %.2f ms\n",
+				mode,
+				float64(server.LatencyStats.Mean) / float64(time.Millisecond),
+				float64(server.LatencyStats.Jitter) / float64(time.Millisecond))
+		}
 	}
 
-	return selected
+	return nil
+}
+
+// Returns the latency modes to measure in addition to the one used for server selection.
+// Warm mode is measured when grading bufferbloat, as loaded latency is compared against it.
+func extraLatencyModes(opts *speedtest.Opts) []speedtest.LatencyMode {
+	var modes []speedtest.LatencyMode
+	warm := latencyMode(opts) == speedtest.LatencyWarm
+	if len(opts.LatencyModes) > 1 {
+		for _, mode := range opts.LatencyModes[1:] {
+			modes = append(modes, mode)
+			warm = warm || mode == speedtest.LatencyWarm
+		}
+	}
+	if opts.Bufferbloat && !warm {
+		modes = append(modes, speedtest.LatencyWarm)
+	}
+	return modes
 }
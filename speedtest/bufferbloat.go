@@ -0,0 +1,88 @@
+package speedtest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const loadedLatencyInterval = 250 * time.Millisecond
+
+// Starts probing server latency while throughput test is running.
+// Probes are sent over connections separate from the ones used by the test.
+// The probe connection is established by a warm-up request not included into samples, like in warm latency mode.
+// The returned function stops probing and returns the loaded latency statistics.
+func (server *Server) probeLoadedLatency(ctx context.Context) (stop func() *LatencyStats) {
+	client := server.client.(*client)
+	if !client.opts.Bufferbloat {
+		return func() *LatencyStats { return nil }
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	samples := make([]time.Duration, 0)
+	failed := 0
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		server.probeLatencyWith(ctx, client.probeGet) // Establish connection
+		ticker := time.NewTicker(loadedLatencyInterval)
+		defer ticker.Stop()
+		for {
+			latency, err := server.probeLatencyWith(ctx, client.probeGet)
+			if ctx.Err() != nil {
+				return // Interrupted probe is not representative
+			}
+			if err != nil {
+				failed++
+			} else {
+				samples = append(samples, latency)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() *LatencyStats {
+		cancel()
+		wg.Wait()
+		stats := NewLatencyStats(samples, failed)
+		return &stats
+	}
+}
+
+// Returns idle latency statistics measured in warm mode, which bufferbloat is graded against,
+// or nil if not measured. Loaded latency is probed with warm HTTP requests, so other modes are not comparable.
+func (server *Server) IdleLatency() *LatencyStats {
+	stats, ok := server.ModeLatencies[LatencyWarm]
+	if !ok {
+		return nil
+	}
+	return &stats
+}
+
+// Grades bufferbloat by the increase of median latency under load compared to idle one.
+// Returns an empty string if there is not enough data.
+func BufferbloatGrade(idle *LatencyStats, loaded *LatencyStats) string {
+	if idle == nil || loaded == nil || len(idle.Samples) == 0 || len(loaded.Samples) == 0 {
+		return ""
+	}
+	increase := loaded.Median - idle.Median
+	switch {
+	case increase < 5 * time.Millisecond:
+		return "A+"
+	case increase < 30 * time.Millisecond:
+		return "A"
+	case increase < 60 * time.Millisecond:
+		return "B"
+	case increase < 200 * time.Millisecond:
+		return "C"
+	case increase < 400 * time.Millisecond:
+		return "D"
+	}
+	return "F"
+}
@@ -0,0 +1,151 @@
+package speedtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBufferbloatGrade(t *testing.T) {
+	ms := time.Millisecond
+	idle := &LatencyStats{Median: 20 * ms, Samples: []time.Duration{20 * ms}}
+	loaded := func(median time.Duration) *LatencyStats {
+		return &LatencyStats{Median: median, Samples: []time.Duration{median}}
+	}
+
+	tests := []struct {
+		name   string
+		idle   *LatencyStats
+		loaded *LatencyStats
+		want   string
+	}{
+		{name: "no idle latency", idle: nil, loaded: loaded(20 * ms), want: ""},
+		{name: "no loaded latency", idle: idle, loaded: nil, want: ""},
+		{name: "no loaded samples", idle: idle, loaded: &LatencyStats{Failed: 3}, want: ""},
+		{name: "decrease", idle: idle, loaded: loaded(10 * ms), want: "A+"},
+		{name: "below 5ms", idle: idle, loaded: loaded(24 * ms), want: "A+"},
+		{name: "5ms", idle: idle, loaded: loaded(25 * ms), want: "A"},
+		{name: "30ms", idle: idle, loaded: loaded(50 * ms), want: "B"},
+		{name: "60ms", idle: idle, loaded: loaded(80 * ms), want: "C"},
+		{name: "200ms", idle: idle, loaded: loaded(220 * ms), want: "D"},
+		{name: "below 400ms", idle: idle, loaded: loaded(419 * ms), want: "D"},
+		{name: "400ms", idle: idle, loaded: loaded(420 * ms), want: "F"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := BufferbloatGrade(tc.idle, tc.loaded); got != tc.want {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v",
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestProbeLoadedLatency(t *testing.T) {
+	var requests int32
+	var mutex sync.Mutex
+	connections := make(map[string]bool)
+	blocked := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		connections[r.RemoteAddr] = true
+		mutex.Unlock()
+		switch atomic.AddInt32(&requests, 1) {
+		case 3:
+			w.WriteHeader(http.StatusInternalServerError)
+		case 5:
+			close(blocked)
+			<-r.Context().Done() // Interrupted by stop
+		default:
+			w.Write([]byte("test=test"))
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		bufferbloat bool
+		wantStats   bool
+		wantSamples int
+		wantFailed  int
+	}{
+		{name: "disabled", bufferbloat: false},
+		{name: "enabled", bufferbloat: true, wantStats: true, wantSamples: 2, wantFailed: 1},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewClient(&Opts{Quiet: true, Timeout: 10 * time.Second, Bufferbloat: tc.bufferbloat})
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			server := &Server{URL: ts.URL + "/speedtest/upload.php", client: c}
+
+			stop := server.probeLoadedLatency(context.Background())
+			if tc.bufferbloat {
+				<-blocked
+			}
+			stats := stop()
+
+			if !tc.wantStats {
+				if stats != nil {
+					t.Errorf("unexpected stats: %+v", stats)
+				}
+				return
+			}
+			if stats == nil {
+				t.Fatalf("no stats")
+			}
+			if got := len(stats.Samples); got != tc.wantSamples {
+				t.Errorf("unexpected samples:\n- want: %v\n-  got: %v", tc.wantSamples, got)
+			}
+			if got := stats.Failed; got != tc.wantFailed {
+				t.Errorf("unexpected failed:\n- want: %v\n-  got: %v", tc.wantFailed, got)
+			}
+
+			time.Sleep(2 * loadedLatencyInterval)
+			mutex.Lock()
+			if got := len(connections); got != 1 {
+				t.Errorf("unexpected connections:\n- want: %v\n-  got: %v", 1, got)
+			}
+			mutex.Unlock()
+			if got := atomic.LoadInt32(&requests); got != 5 {
+				t.Errorf("unexpected probes after stop:\n- want: %v\n-  got: %v", 5, got)
+			}
+		})
+	}
+}
+
+func TestServer_IdleLatency(t *testing.T) {
+	warm := LatencyStats{Median: 20 * time.Millisecond, Samples: []time.Duration{20 * time.Millisecond}}
+	cold := LatencyStats{Median: 60 * time.Millisecond, Samples: []time.Duration{60 * time.Millisecond}}
+
+	tests := []struct {
+		name  string
+		modes map[LatencyMode]LatencyStats
+		want  *LatencyStats
+	}{
+		{name: "not measured", modes: nil, want: nil},
+		{name: "cold only", modes: map[LatencyMode]LatencyStats{LatencyCold: cold}, want: nil},
+		{name: "warm", modes: map[LatencyMode]LatencyStats{LatencyCold: cold, LatencyWarm: warm}, want: &warm},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{LatencyStats: cold, ModeLatencies: tc.modes}
+			if got := server.IdleLatency(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v",
+					tc.want, got)
+			}
+		})
+	}
+}
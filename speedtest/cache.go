@@ -0,0 +1,109 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const DefaultCacheTTL = time.Hour
+
+// Cache file contents.
+type cacheEntry struct {
+	Stored time.Time       `json:"stored"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// Returns the directory to cache speedtest.net responses in, or empty string if caching is disabled.
+func cacheDir(opts *Opts) string {
+	if opts.CacheTTL <= 0 {
+		return ""
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "speedtest-cli")
+}
+
+// Returns the name of the cached configuration.
+// The configuration contains client IP address and ISP, so it is cached separately per network interface and protocol.
+func (client *client) configCacheName() string {
+	name := "config"
+	if len(client.opts.Interface) != 0 {
+		name += "-" + strings.NewReplacer(":", "_", "%", "_").Replace(client.opts.Interface)
+	}
+	if client.opts.Secure {
+		name += "-secure"
+	}
+	return name + ".json"
+}
+
+// Loads the cached value with the given name.
+// Returns whether the value found, and whether it is stored within cache TTL.
+// The value is never fresh when refresh option is set, but may still be used when retrieval fails.
+func (client *client) loadCached(name string, value interface{}) (found bool, fresh bool) {
+	if len(client.cacheDir) == 0 {
+		return false, false
+	}
+	content, err := ioutil.ReadFile(filepath.Join(client.cacheDir, name))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			client.Log("Failed to read cache: %v", err)
+		}
+		return false, false
+	}
+	var entry cacheEntry
+	if err = json.Unmarshal(content, &entry); err == nil {
+		err = json.Unmarshal(entry.Value, value)
+	}
+	if err != nil {
+		client.Log("Failed to read cache: %v", err)
+		return false, false
+	}
+	return true, !client.opts.Refresh && time.Since(entry.Stored) < client.opts.CacheTTL
+}
+
+// Stores the value with the given name in the cache.
+func (client *client) storeCached(name string, value interface{}) {
+	if len(client.cacheDir) == 0 {
+		return
+	}
+	if err := client.writeCache(name, value); err != nil {
+		client.Log("Failed to write cache: %v", err)
+	}
+}
+
+func (client *client) writeCache(name string, value interface{}) error {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	content, err = json.Marshal(cacheEntry{Stored: time.Now(), Value: content})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(client.cacheDir, 0755); err != nil {
+		return err
+	}
+
+	// Write to temporary file first, as another process may read the cache simultaneously.
+	file, err := ioutil.TempFile(client.cacheDir, name+".*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(content)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(client.cacheDir, name))
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
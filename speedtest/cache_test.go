@@ -0,0 +1,225 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClient_cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "speedtest-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &Config{
+		Client: ClientConfig{IP: "192.0.2.1", ISP: "Test ISP"},
+		Times:  ConfigTimes{{Upload: 100, Download: 200}},
+	}
+
+	tests := []struct {
+		name      string
+		opts      Opts
+		stored    time.Time
+		wantFound bool
+		wantFresh bool
+	}{
+		{name: "fresh", opts: Opts{CacheTTL: time.Hour}, wantFound: true, wantFresh: true},
+		{name: "stale", opts: Opts{CacheTTL: time.Hour}, stored: time.Now().Add(-2 * time.Hour), wantFound: true},
+		{name: "refresh", opts: Opts{CacheTTL: time.Hour, Refresh: true}, wantFound: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := &client{opts: &tc.opts, cacheDir: dir}
+			c.storeCached("config.json", config)
+			if !tc.stored.IsZero() {
+				value, _ := json.Marshal(config)
+				content, _ := json.Marshal(cacheEntry{Stored: tc.stored, Value: value})
+				if err := ioutil.WriteFile(filepath.Join(dir, "config.json"), content, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			loaded := &Config{}
+			found, fresh := c.loadCached("config.json", loaded)
+			if found != tc.wantFound || fresh != tc.wantFresh {
+				t.Errorf("unexpected result:\n- want: %v %v\n-  got: %v %v", tc.wantFound, tc.wantFresh, found, fresh)
+			}
+			if found && !reflect.DeepEqual(loaded, config) {
+				t.Errorf("unexpected config:\n- want: %+v\n-  got: %+v", config, loaded)
+			}
+		})
+	}
+}
+
+func TestClient_configCacheName(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Opts
+		want string
+	}{
+		{name: "default", opts: Opts{}, want: "config.json"},
+		{name: "secure", opts: Opts{Secure: true}, want: "config-secure.json"},
+		{name: "interface", opts: Opts{Interface: "192.0.2.1"}, want: "config-192.0.2.1.json"},
+		{name: "IPv6 interface", opts: Opts{Interface: "2001:db8::1", Secure: true}, want: "config-2001_db8__1-secure.json"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := &client{opts: &tc.opts}
+			if got := c.configCacheName(); got != tc.want {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_cacheServers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "speedtest-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := &Server{ID: 1234, Sponsor: "Sponsor", Distance: 12.5, Latency: time.Second}
+	c := &client{opts: &Opts{CacheTTL: time.Hour}, cacheDir: dir}
+	c.storeCached("servers.json", &Servers{List: []*Server{server}})
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "servers.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"Distance", "Latency", "LatencyStats", "LatencyPhases", "ModeLatencies"} {
+		if strings.Contains(string(content), `"`+field+`"`) {
+			t.Errorf("unexpected field %s cached: %s", field, content)
+		}
+	}
+
+	loaded := &Servers{}
+	if found, _ := c.loadCached("servers.json", loaded); !found {
+		t.Fatalf("servers not found in cache")
+	}
+	if loaded.Len() != 1 || loaded.List[0].ID != server.ID || loaded.List[0].Sponsor != server.Sponsor {
+		t.Errorf("unexpected servers: %v", loaded)
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClient_staleWhileError(t *testing.T) {
+	config := &Config{Client: ClientConfig{IP: "192.0.2.1", ISP: "Test ISP"}}
+	servers := &Servers{List: []*Server{
+		{ID: 2, Coordinates: Coordinates{Latitude: 10, Longitude: 10}},
+		{ID: 1, Coordinates: Coordinates{Latitude: 1, Longitude: 1}},
+	}}
+
+	tests := []struct {
+		name           string
+		refresh        bool
+		fresh          bool
+		cached         map[string]interface{}
+		wantConfig     bool
+		wantConfigErr  bool
+		wantServers    []ServerID
+		wantServersErr error
+	}{
+		{
+			name:        "stale config and servers",
+			cached:      map[string]interface{}{"config.json": config, "servers.json": servers},
+			wantConfig:  true,
+			wantServers: []ServerID{1, 2},
+		},
+		{
+			name:        "refresh",
+			refresh:     true,
+			fresh:       true,
+			cached:      map[string]interface{}{"config.json": config, "servers.json": servers},
+			wantConfig:  true,
+			wantServers: []ServerID{1, 2},
+		},
+		{
+			name:           "stale config only",
+			cached:         map[string]interface{}{"config.json": config},
+			wantConfig:     true,
+			wantServersErr: NoServersError,
+		},
+		{
+			name:           "nothing cached",
+			cached:         map[string]interface{}{},
+			wantConfigErr:  true,
+			wantServersErr: NoServersError,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "speedtest-cache")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			stored := time.Now().Add(-2 * time.Hour)
+			if tc.fresh {
+				stored = time.Now()
+			}
+			for name, value := range tc.cached {
+				content, _ := json.Marshal(value)
+				content, _ = json.Marshal(cacheEntry{Stored: stored, Value: content})
+				if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fetched := int32(0)
+			c, err := NewClient(&Opts{Quiet: true, CacheTTL: time.Hour, Refresh: tc.refresh, Timeout: time.Second})
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			c.(*client).cacheDir = dir
+			c.(*client).Transport = roundTripFunc(func(*http.Request) (*http.Response, error) {
+				atomic.AddInt32(&fetched, 1)
+				return nil, errors.New("unreachable")
+			})
+
+			gotConfig, err := c.Config()
+			if (err != nil) != tc.wantConfigErr {
+				t.Fatalf("unexpected config error: %v", err)
+			}
+			if tc.wantConfig && !reflect.DeepEqual(gotConfig, config) {
+				t.Errorf("unexpected config:\n- want: %+v\n-  got: %+v", config, gotConfig)
+			}
+
+			gotServers, err := c.AllServers()
+			if err != tc.wantServersErr {
+				t.Fatalf("unexpected servers error: %v", err)
+			}
+			var gotIDs []ServerID
+			if gotServers != nil {
+				for _, server := range gotServers.List {
+					gotIDs = append(gotIDs, server.ID)
+				}
+			}
+			if !reflect.DeepEqual(gotIDs, tc.wantServers) {
+				t.Errorf("unexpected servers:\n- want: %v\n-  got: %v", tc.wantServers, gotIDs)
+			}
+
+			if atomic.LoadInt32(&fetched) == 0 {
+				t.Errorf("stale entries not refreshed")
+			}
+		})
+	}
+}
@@ -1,13 +1,13 @@
 package speedtest
 
 import (
+	"context"
 	"net/http"
 	"fmt"
 	"runtime"
 	"strings"
 	"io"
 	"net"
-	"log"
 	"encoding/xml"
 	"io/ioutil"
 	"sync"
@@ -16,19 +16,31 @@ import (
 type Client interface {
 	Log(format string, a ...interface{})
 	Config() (*Config, error)
+	ConfigContext(ctx context.Context) (*Config, error)
 	LoadConfig(ret chan ConfigRef)
 	NewRequest(method string, url string, body io.Reader) (*http.Request, error)
+	NewRequestContext(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error)
 	Get(url string) (resp *Response, err error)
+	GetContext(ctx context.Context, url string) (resp *Response, err error)
 	Post(url string, bodyType string, body io.Reader) (resp *Response, err error)
+	PostContext(ctx context.Context, url string, bodyType string, body io.Reader) (resp *Response, err error)
+	Do(req *http.Request) (resp *Response, err error)
 	AllServers() (*Servers, error)
+	AllServersContext(ctx context.Context) (*Servers, error)
 	LoadAllServers(ret chan ServersRef)
 	ClosestServers() (*Servers, error)
+	ClosestServersContext(ctx context.Context) (*Servers, error)
 	LoadClosestServers(ret chan ServersRef)
 }
 
 type client struct {
 	http.Client
 	opts           *Opts
+	probeClient    http.Client // Separate client with its own connections for probing latency under load
+	coldClient     http.Client // Client establishing new connection for each request
+	dialer         *net.Dialer
+	wire           wireCounter // Raw bytes transferred over the connections of the main client
+	cacheDir       string      // Directory to cache speedtest.net responses in, or empty string if caching disabled
 	mutex          sync.Mutex
 	config         chan ConfigRef
 	allServers     chan ServersRef
@@ -37,38 +49,61 @@ type client struct {
 
 type Response http.Response
 
-func NewClient(opts *Opts) Client {
+// Creates a client with the given options.
+// Returns BindAddressError if the network interface address is invalid.
+func NewClient(opts *Opts) (Client, error) {
 	dialer := &net.Dialer{
 		Timeout: opts.Timeout,
 		KeepAlive: opts.Timeout,
 	}
 
 	if len(opts.Interface) != 0 {
-		dialer.LocalAddr = &net.IPAddr{IP: net.ParseIP(opts.Interface)}
-		if dialer.LocalAddr == nil {
-			log.Fatalf("Invalid source IP: %s\n", opts.Interface)
+		ip := net.ParseIP(opts.Interface)
+		if ip == nil {
+			return nil, &BindAddressError{opts.Interface}
 		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
 	}
 
+	client := &client{opts: opts, dialer: dialer, cacheDir: cacheDir(opts)}
+
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
-		Dial: dialer.Dial,
+		DialContext: client.wire.dialer(dialer.DialContext),
 		TLSHandshakeTimeout: opts.Timeout,
 		ExpectContinueTimeout: opts.Timeout,
 	}
+	client.Client = http.Client{
+		Transport: transport,
+		Timeout: opts.Timeout,
+	}
+
+	probeTransport := transport.Clone()
+	probeTransport.DialContext = dialer.DialContext
+	client.probeClient = http.Client{
+		Transport: probeTransport,
+		Timeout: opts.Timeout,
+	}
 
-	client := &client{
-		Client: http.Client{
-			Transport: transport,
-			Timeout: opts.Timeout,
-		},
-		opts: opts,
+	coldTransport := probeTransport.Clone()
+	coldTransport.DisableKeepAlives = true
+	client.coldClient = http.Client{
+		Transport: coldTransport,
+		Timeout: opts.Timeout,
 	}
 
-	return client;
+	return client, nil
 }
 
 func (client *client) NewRequest(method string, url string, body io.Reader) (*http.Request, error) {
+	return client.NewRequestContext(context.Background(), method, url, body)
+}
+
+func (client *client) NewRequestContext(
+	ctx context.Context,
+	method string,
+	url string,
+	body io.Reader) (*http.Request, error) {
 	if strings.HasPrefix(url, ":") {
 		if client.opts.Secure {
 			url = "https" + url
@@ -76,7 +111,7 @@ func (client *client) NewRequest(method string, url string, body io.Reader) (*ht
 			url = "http" + url
 		}
 	}
-	req, err := http.NewRequest(method, url, body);
+	req, err := http.NewRequestWithContext(ctx, method, url, body);
 	if err == nil {
 		req.Header.Set(
 			"User-Agent",
@@ -89,7 +124,11 @@ func (client *client) NewRequest(method string, url string, body io.Reader) (*ht
 }
 
 func (client *client) Get(url string) (resp *Response, err error) {
-	req, err := client.NewRequest("GET", url, nil);
+	return client.GetContext(context.Background(), url)
+}
+
+func (client *client) GetContext(ctx context.Context, url string) (resp *Response, err error) {
+	req, err := client.NewRequestContext(ctx, "GET", url, nil);
 	if err != nil {
 		return nil, err
 	}
@@ -99,13 +138,51 @@ func (client *client) Get(url string) (resp *Response, err error) {
 	return (*Response)(htResp), err;
 }
 
+// Performs GET request using connections separate from the ones used by Get.
+func (client *client) probeGet(ctx context.Context, url string) (resp *Response, err error) {
+	req, err := client.NewRequestContext(ctx, "GET", url, nil);
+	if err != nil {
+		return nil, err
+	}
+
+	htResp, err := client.probeClient.Do(req)
+
+	return (*Response)(htResp), err;
+}
+
+// Performs GET request over a new connection.
+func (client *client) coldGet(ctx context.Context, url string) (resp *Response, err error) {
+	req, err := client.NewRequestContext(ctx, "GET", url, nil);
+	if err != nil {
+		return nil, err
+	}
+
+	htResp, err := client.coldClient.Do(req)
+
+	return (*Response)(htResp), err;
+}
+
 func (client *client) Post(url string, bodyType string, body io.Reader) (resp *Response, err error) {
-	req, err := client.NewRequest("POST", url, body)
+	return client.PostContext(context.Background(), url, bodyType, body)
+}
+
+func (client *client) PostContext(
+	ctx context.Context,
+	url string,
+	bodyType string,
+	body io.Reader) (resp *Response, err error) {
+	req, err := client.NewRequestContext(ctx, "POST", url, body)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", bodyType)
+
+	return client.Do(req)
+}
+
+// Sends the request created by NewRequest or NewRequestContext.
+func (client *client) Do(req *http.Request) (resp *Response, err error) {
 	htResp, err := client.Client.Do(req)
 
 	return (*Response)(htResp), err;
@@ -1,10 +1,12 @@
 package speedtest
 
 import (
+	"context"
 	"encoding/xml"
 	"strings"
 	"strconv"
 	"log"
+	"fmt"
 )
 
 type ClientConfig struct {
@@ -18,11 +20,13 @@ type ClientConfig struct {
 	LoggedIn           uint8 `xml:"loggedin,attr"`
 }
 
+// Line speed thresholds in bits per second.
 type ConfigTime struct {
 	Upload   uint32
 	Download uint32
 }
 
+// Line speed thresholds in ascending order, parsed from `dl1`, `ul1`, `dl2`, `ul2`, etc. attributes.
 type ConfigTimes []ConfigTime
 
 type Config struct {
@@ -42,50 +46,93 @@ type ConfigRef struct {
 }
 
 func (client *client) Config() (*Config, error) {
-	configChan := make(chan ConfigRef)
-	client.LoadConfig(configChan)
-	configRef := <-configChan
-	return configRef.Config, configRef.Error
+	return client.ConfigContext(context.Background())
+}
+
+func (client *client) ConfigContext(ctx context.Context) (*Config, error) {
+	configChan := make(chan ConfigRef, 1)
+	client.loadConfigContext(ctx, configChan)
+	select {
+	case configRef := <-configChan:
+		return configRef.Config, configRef.Error
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 func (client *client) LoadConfig(ret chan ConfigRef) {
+	client.loadConfigContext(context.Background(), ret)
+}
+
+// Loads the configuration unless the context is done.
+// The configuration is loaded once and shared by subsequent calls, unless the loading is interrupted.
+func (client *client) loadConfigContext(ctx context.Context, ret chan ConfigRef) {
 	client.mutex.Lock()
 	defer client.mutex.Unlock()
 
 	if client.config == nil {
 		client.config = make(chan ConfigRef)
-		go client.loadConfig()
+		go client.loadConfig(ctx, client.config)
 	}
 
+	config := client.config
 	go func() {
-		result := <-client.config
+		result := <-config
 		ret <- result
-		client.config <- result
+		config <- result
 	}()
 }
 
-func (client *client) loadConfig() {
+func (client *client) loadConfig(ctx context.Context, config chan ConfigRef) {
+	cached := &Config{}
+	found, fresh := client.loadCached(client.configCacheName(), cached)
+	if fresh {
+		config <- ConfigRef{cached, nil}
+		return
+	}
+
+	result := client.fetchConfig(ctx)
+	if result.Error == nil {
+		client.storeCached(client.configCacheName(), result.Config)
+	} else if ctx.Err() != nil {
+		result.Error = ctx.Err()
+		client.mutex.Lock()
+		client.config = nil // Load again next time
+		client.mutex.Unlock()
+	} else if found {
+		client.Log("%v. Using cached configuration", result.Error)
+		result = ConfigRef{cached, nil}
+	}
+
+	config <- result
+}
+
+func (client *client) fetchConfig(ctx context.Context) ConfigRef {
 	client.Log("Retrieving speedtest.net configuration...")
 
 	result := ConfigRef{}
 
-	resp, err := client.Get("://www.speedtest.net/speedtest-config.php")
+	resp, err := client.GetContext(ctx, "://www.speedtest.net/speedtest-config.php")
 	if err != nil {
-		result.Error = err
+		result.Error = &ConfigError{err}
+	} else if resp.StatusCode != 200 {
+		resp.Body.Close()
+		result.Error = &ConfigError{&HTTPStatusError{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode}}
 	} else {
 		config := &Config{}
 		err = resp.ReadXML(config)
 		if err != nil {
-			result.Error = err
+			result.Error = &ConfigError{err}
 		} else {
 			result.Config = config
 		}
 	}
 
-	client.config <- result
+	return result
 }
 
-func (times ConfigTimes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+func (times *ConfigTimes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	result := *times
 	for _, attr := range start.Attr {
 		name := attr.Name.Local
 		if dl := strings.HasPrefix(name, "dl"); dl || strings.HasPrefix(name, "ul") {
@@ -93,10 +140,13 @@ func (times ConfigTimes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 			if err != nil {
 				return err;
 			}
-			if num > cap(times) {
+			if num < 1 {
+				return fmt.Errorf("Invalid config time attribute: %s", name)
+			}
+			if num > len(result) {
 				newTimes := make([]ConfigTime, num)
-				copy(newTimes, times)
-				times = newTimes[0:num]
+				copy(newTimes, result)
+				result = newTimes
 			}
 
 			speed, err := strconv.ParseUint(attr.Value, 10, 32);
@@ -105,13 +155,14 @@ func (times ConfigTimes) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 				return err
 			}
 			if dl {
-				times[num - 1].Download = uint32(speed)
+				result[num - 1].Download = uint32(speed)
 			} else {
-				times[num - 1].Upload = uint32(speed)
+				result[num - 1].Upload = uint32(speed)
 			}
 		}
 	}
 
+	*times = result
+
 	return d.Skip()
 }
-
@@ -0,0 +1,57 @@
+package speedtest
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestConfigTimes_UnmarshalXML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    ConfigTimes
+		wantErr bool
+	}{
+		{
+			name:  "no times",
+			input: `<settings><times/></settings>`,
+			want:  ConfigTimes{},
+		},
+		{
+			name:  "download and upload times",
+			input: `<settings><times dl1="5000000" dl2="35000000" dl3="800000000" ul1="1000000" ul2="8000000" ul3="35000000"/></settings>`,
+			want: ConfigTimes{
+				{Download: 5000000, Upload: 1000000},
+				{Download: 35000000, Upload: 8000000},
+				{Download: 800000000, Upload: 35000000},
+			},
+		},
+		{
+			name:    "invalid number",
+			input:   `<settings><times dl0="5000000"/></settings>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{Times: ConfigTimes{}}
+			err := xml.Unmarshal([]byte(tc.input), config)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", config.Times)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := config.Times, tc.want; !reflect.DeepEqual(got, want) {
+				t.Fatalf("unexpected result:\n- want: %v\n-  got: %v",
+					want, got)
+			}
+		})
+	}
+}
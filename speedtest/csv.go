@@ -0,0 +1,95 @@
+package speedtest
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// CSV columns in the same order as used by the original Python speedtest-cli.
+var CSVHeader = []string{
+	"Server ID",
+	"Sponsor",
+	"Server Name",
+	"Timestamp",
+	"Distance",
+	"Ping",
+	"Download",
+	"Upload",
+	"Share",
+	"IP Address",
+}
+
+// Converts the result to CSV record with columns described by CSVHeader.
+// Download and upload speeds are in bits per second.
+func (result *Result) CSVRecord() []string {
+	return []string{
+		strconv.FormatUint(uint64(result.Server.ID), 10),
+		result.Server.Sponsor,
+		result.Server.Name,
+		result.Timestamp.Format(time.RFC3339Nano),
+		formatOptionalFloat(result.Server.Distance),
+		formatFloat(result.Server.LatencyMs),
+		formatFloat(bitsPerSecond(result.Download)),
+		formatFloat(bitsPerSecond(result.Upload)),
+		result.Share,
+		clientIP(result.Client),
+	}
+}
+
+// Writes the result as a single CSV row.
+func (result *Result) WriteCSV(out io.Writer, delimiter rune) error {
+	return writeCSV(out, delimiter, result.CSVRecord())
+}
+
+// Writes a CSV row per each server.
+func (result *MatrixResult) WriteCSV(out io.Writer, delimiter rune) error {
+	for _, server := range result.Servers {
+		if err := server.WriteCSV(out, delimiter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes CSV header row.
+func WriteCSVHeader(out io.Writer, delimiter rune) error {
+	return writeCSV(out, delimiter, CSVHeader)
+}
+
+func writeCSV(out io.Writer, delimiter rune, record []string) error {
+	writer := csv.NewWriter(out)
+	writer.Comma = delimiter
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func bitsPerSecond(transfer *TransferResult) float64 {
+	if transfer == nil {
+		return 0
+	}
+	return transfer.BitsPerSecond
+}
+
+func clientIP(client *ClientResult) string {
+	if client == nil {
+		return ""
+	}
+	return client.IP
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Formats the value, or returns an empty string if there is no value.
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return formatFloat(*value)
+}
@@ -0,0 +1,55 @@
+package speedtest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestResult_WriteCSV(t *testing.T) {
+	distance := 12.5
+	result := &Result{
+		Timestamp: time.Date(2018, 3, 1, 12, 30, 0, 0, time.UTC),
+		Client:    &ClientResult{IP: "192.0.2.1"},
+		Server: ServerResult{
+			ID:        1234,
+			Sponsor:   "Example, Inc.",
+			Name:      "Springfield",
+			Distance:  &distance,
+			LatencyMs: 20.25,
+		},
+		Download: &TransferResult{BitsPerSecond: 1000000},
+		Upload:   &TransferResult{BitsPerSecond: 500000},
+	}
+
+	tests := []struct {
+		name      string
+		delimiter rune
+		want      string
+	}{
+		{
+			name:      "comma delimiter",
+			delimiter: ',',
+			want:      "1234,\"Example, Inc.\",Springfield,2018-03-01T12:30:00Z,12.5,20.25,1000000,500000,,192.0.2.1\n",
+		},
+		{
+			name:      "semicolon delimiter",
+			delimiter: ';',
+			want:      "1234;Example, Inc.;Springfield;2018-03-01T12:30:00Z;12.5;20.25;1000000;500000;;192.0.2.1\n",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := result.WriteCSV(out, tc.delimiter); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := out.String(), tc.want; got != want {
+				t.Fatalf("unexpected result:\n- want: %q\n-  got: %q",
+					want, got)
+			}
+		})
+	}
+}
@@ -1,93 +1,110 @@
 package speedtest
 
 import (
-	"time"
+	"context"
 	"log"
 	"io"
 	"fmt"
-	"os"
 )
 
-const downloadStreamLimit = 6
-const maxDownloadDuration = 10 * time.Second
-const downloadBufferSize = 4096
-const downloadRepeats = 5
-
-var downloadImageSizes = []int{350, 500, 750, 1000, 1500, 2000, 2500, 3000, 3500, 4000}
-
-func (client *client) downloadFile(url string, start time.Time, ret chan int) {
-	totalRead := 0
+func (client *client) downloadFile(
+	ctx context.Context,
+	task transferTask,
+	run *transferRun,
+	ret chan transfer) {
+	result := transfer{server: task.server, size: task.size}
 	defer func() {
-		ret <- totalRead
+		ret <- result
 	}()
 
-	if (time.Since(start) > maxDownloadDuration) {
+	if (run.expired() || ctx.Err() != nil) {
 		return;
 	}
-	if !client.opts.Quiet {
-		os.Stdout.WriteString(".")
-		os.Stdout.Sync()
-	}
 
-	resp, err := client.Get(url)
+	result.started = true
+	run.startRequest()
+
+	traceCtx, timing := traceRequest(ctx)
+	resp, err := client.GetContext(traceCtx, task.url)
+	result.timing = timing()
 	if err != nil {
-		log.Printf("[%s] Download failed: %v\n", url, err)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		} else {
+			log.Printf("[%s] Download failed: %v\n", task.url, err)
+		}
+		result.err = err
 		return;
 	}
 
 	defer resp.Body.Close()
 
-	buf := make([]byte, downloadBufferSize)
-	for time.Since(start) <= maxDownloadDuration {
+	if resp.StatusCode != 200 {
+		result.err = &HTTPStatusError{URL: task.url, StatusCode: resp.StatusCode}
+		log.Println(result.err)
+		return
+	}
+
+	buf := make([]byte, run.config.bufferSize)
+	for !run.expired() {
 		read, err := resp.Body.Read(buf)
-		totalRead += read
+		result.bytes += int64(read)
+		run.addBytes(int64(read))
 		if err != nil {
-			if err != io.EOF {
-				log.Printf("[%s] Download error: %v\n", url, err)
+			if ctx.Err() != nil {
+				result.err = ctx.Err()
+			} else if err != io.EOF {
+				log.Printf("[%s] Download error: %v\n", task.url, err)
+				result.err = err
 			}
 			break
 		}
 	}
 }
 
+// Measures download speed of the server in bytes per second.
 func (server *Server) DownloadSpeed() int {
-	client := server.client.(*client)
-	if !client.opts.Quiet {
-		os.Stdout.WriteString("Testing download speed: ")
-		os.Stdout.Sync()
-	}
-
-	starterChan := make(chan int, downloadStreamLimit)
-	downloads := downloadRepeats * len(downloadImageSizes)
-	resultChan := make(chan int, downloadStreamLimit)
-	start := time.Now()
+	return server.Download().Speed()
+}
 
-	go func() {
-		for _, size := range downloadImageSizes {
-			for i := 0; i < downloadRepeats; i++ {
-				url := server.RelativeURL(fmt.Sprintf("random%dx%d.jpg", size, size))
-				starterChan <- 1
-				go func() {
-					client.downloadFile(url, start, resultChan)
-					<-starterChan
-				}()
-			}
-		}
-		close(starterChan)
-	}()
+// Performs a download test against the server.
+func (server *Server) Download() *Measurement {
+	return server.DownloadContext(context.Background())
+}
 
-	var totalSize int64 = 0;
+// Performs a download test against the server unless the context is done.
+// Uses the test profile specified in client options.
+// When interrupted, returns the partial measurement with the context error.
+func (server *Server) DownloadContext(ctx context.Context) *Measurement {
+	return server.DownloadProfile(ctx, server.client.(*client).profile())
+}
 
-	for i := 0; i < downloads; i++ {
-		totalSize += int64(<-resultChan)
+// Performs a download test against the server with the given test profile unless the context is done.
+// When interrupted, returns the partial measurement with the context error.
+func (server *Server) DownloadProfile(ctx context.Context, profile *TestProfile) *Measurement {
+	client := server.client.(*client)
+	tasks, err := server.downloadTasks(profile)
+	if err != nil {
+		return &Measurement{Err: err}
 	}
 
-	if !client.opts.Quiet {
-		os.Stdout.WriteString("\n")
-		os.Stdout.Sync()
-	}
+	stopProbing := server.probeLoadedLatency(ctx)
+	measurement := client.measure(ctx, profile.transferConfig(PhaseDownload, tasks), client.downloadFile)
+	measurement.LoadedLatency = stopProbing()
 
-	duration := time.Since(start);
+	return measurement
+}
 
-	return int(totalSize * int64(time.Second) / int64(duration))
+func (server *Server) downloadTasks(profile *TestProfile) ([]transferTask, error) {
+	tasks := make([]transferTask, 0, profile.DownloadRepeats * len(profile.DownloadSizes))
+	for _, size := range profile.DownloadSizes {
+		url, err := server.RelativeURL(fmt.Sprintf("random%dx%d.jpg", size, size))
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < profile.DownloadRepeats; i++ {
+			tasks = append(tasks, transferTask{server: server, url: url, size: size})
+		}
+	}
+	return tasks, nil
 }
@@ -0,0 +1,63 @@
+package speedtest
+
+import "fmt"
+
+// Server URL is invalid.
+type ServerURLError struct {
+	URL string
+	Err error
+}
+
+func (e *ServerURLError) Error() string {
+	return fmt.Sprintf("Invalid server URL `%s`: %v", e.URL, e.Err)
+}
+
+func (e *ServerURLError) Unwrap() error {
+	return e.Err
+}
+
+// Address of network interface to bind to is invalid.
+type BindAddressError struct {
+	Address string
+}
+
+func (e *BindAddressError) Error() string {
+	return fmt.Sprintf("Invalid source IP: %s", e.Address)
+}
+
+// Failed to retrieve speedtest.net configuration.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("Failed to retrieve speedtest.net configuration: %v", e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Unexpected HTTP response status.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("[%s] Unexpected HTTP status: %d", e.URL, e.StatusCode)
+}
+
+// Server with the given ID is not found in the server list.
+// Matches NoServersError.
+type ServerNotFoundError struct {
+	ID ServerID
+}
+
+func (e *ServerNotFoundError) Error() string {
+	return fmt.Sprintf("Server not found: %d", e.ID)
+}
+
+func (e *ServerNotFoundError) Is(target error) bool {
+	return target == NoServersError
+}
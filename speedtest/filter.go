@@ -0,0 +1,78 @@
+package speedtest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Server filter. Zero value matches any server.
+type ServerFilter struct {
+	Country     string         // ISO country code, case-insensitive
+	Sponsor     *regexp.Regexp // Matches either sponsor or server name
+	Host        *regexp.Regexp // Matches server host
+	MaxDistance float64        // Maximum distance in kilometers, or zero for any distance, including unknown one
+	Include     []ServerID     // When not empty, only servers with these IDs match
+	Exclude     []ServerID     // Servers with these IDs never match
+}
+
+// Whether the filter matches the given server.
+func (filter *ServerFilter) Match(server *Server) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.Country) != 0 && !strings.EqualFold(filter.Country, server.CC) {
+		return false
+	}
+	if filter.Sponsor != nil && !filter.Sponsor.MatchString(server.Sponsor) && !filter.Sponsor.MatchString(server.Name) {
+		return false
+	}
+	if filter.Host != nil && !filter.Host.MatchString(server.Host) {
+		return false
+	}
+	if filter.MaxDistance > 0 && (!server.HasDistance() || server.Distance > filter.MaxDistance) {
+		return false
+	}
+	if len(filter.Include) != 0 && !containsServerID(filter.Include, server.ID) {
+		return false
+	}
+	return !containsServerID(filter.Exclude, server.ID)
+}
+
+func containsServerID(ids []ServerID, id ServerID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns servers matching the given filter, preserving their order.
+func (servers *Servers) Filter(filter *ServerFilter) *Servers {
+	filtered := &Servers{List: make([]*Server, 0, servers.Len())}
+	for _, server := range servers.List {
+		if filter.Match(server) {
+			filtered.List = append(filtered.List, server)
+		}
+	}
+	return filtered
+}
+
+// Parses comma-separated list of server IDs.
+func ParseServerIDs(list string) ([]ServerID, error) {
+	var ids []ServerID
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		id, err := strconv.ParseUint(item, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid server ID: %s", item)
+		}
+		ids = append(ids, ServerID(id))
+	}
+	return ids, nil
+}
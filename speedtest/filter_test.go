@@ -0,0 +1,42 @@
+package speedtest
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestServers_Filter(t *testing.T) {
+	servers := &Servers{List: []*Server{
+		{ID: 1, CC: "DE", Sponsor: "Telekom", Name: "Berlin", Host: "speed.telekom.de:8080", Distance: 10},
+		{ID: 2, CC: "DE", Sponsor: "Vodafone", Name: "Hamburg", Host: "speed.vodafone.de:8080", Distance: 300},
+		{ID: 3, CC: "PL", Sponsor: "Orange", Name: "Szczecin", Host: "speedtest.orange.pl:8080", Distance: 150},
+		{ID: 4, CC: "NL", Sponsor: "Lab", Name: "Amsterdam", Host: "speed.lab.example.nl:8080", Distance: UnknownDistance},
+	}}
+	tests := []struct {
+		name   string
+		filter *ServerFilter
+		want   []ServerID
+	}{
+		{name: "no filter", filter: nil, want: []ServerID{1, 2, 3, 4}},
+		{name: "country", filter: &ServerFilter{Country: "de"}, want: []ServerID{1, 2}},
+		{name: "sponsor", filter: &ServerFilter{Sponsor: regexp.MustCompile("(?i)orange")}, want: []ServerID{3}},
+		{name: "name", filter: &ServerFilter{Sponsor: regexp.MustCompile("^Ham")}, want: []ServerID{2}},
+		{name: "host", filter: &ServerFilter{Host: regexp.MustCompile(`\.de:`)}, want: []ServerID{1, 2}},
+		{name: "max distance", filter: &ServerFilter{MaxDistance: 200}, want: []ServerID{1, 3}},
+		{name: "include", filter: &ServerFilter{Include: []ServerID{3, 2}}, want: []ServerID{2, 3}},
+		{name: "exclude", filter: &ServerFilter{Country: "DE", Exclude: []ServerID{1}}, want: []ServerID{2}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := []ServerID{}
+			for _, server := range servers.Filter(tc.filter).List {
+				got = append(got, server.ID)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v", tc.want, got)
+			}
+		})
+	}
+}
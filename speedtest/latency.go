@@ -1,6 +1,9 @@
 package speedtest
 
 import (
+	"context"
+	"errors"
+	"math"
 	"time"
 	"strings"
 	"sort"
@@ -10,23 +13,45 @@ const DefaultLatencyMeasureTimes = 4
 const DefaultErrorLatency = time.Hour
 
 // Measures latencies for each server.
-// Returns server list sorted by latencies.
+// Returns server list sorted by median latencies. Servers with most of latency probes failed go last.
 // This is synchronous operation, because multiple simultaneous requests may affect results.
 func (servers *Servers) MeasureLatencies(times uint, errorLatency time.Duration) *Servers {
+	sorted, _ := servers.MeasureLatenciesContext(context.Background(), times, errorLatency)
+	return sorted
+}
+
+// Measures latencies for each server unless the context is done.
+// Returns server list sorted by latencies, and context error if latency measurement has been interrupted.
+func (servers *Servers) MeasureLatenciesContext(
+	ctx context.Context,
+	times uint,
+	errorLatency time.Duration) (*Servers, error) {
+	return servers.MeasureLatenciesMode(ctx, LatencyWarm, times, errorLatency)
+}
+
+// Measures latencies for each server in the given mode unless the context is done.
+// Returns server list sorted by latencies, and context error if latency measurement has been interrupted.
+func (servers *Servers) MeasureLatenciesMode(
+	ctx context.Context,
+	mode LatencyMode,
+	times uint,
+	errorLatency time.Duration) (*Servers, error) {
 	first := true
 	for _, server := range servers.List {
 		if first {
 			first = false
 			server.client.Log("Measuring server latencies...")
 		}
-		server.doMeasureLatency(times, errorLatency)
+		if _, err := server.doMeasureLatency(ctx, mode, times, errorLatency); err != nil {
+			break
+		}
 	}
 
 	latencies := &serverLatencies{List: make([]*Server, servers.Len())}
 	copy(latencies.List, servers.List)
 	sort.Sort(latencies)
 
-	return (*Servers)(latencies)
+	return (*Servers)(latencies), ctx.Err()
 }
 
 type serverLatencies Servers
@@ -36,7 +61,12 @@ func (servers *serverLatencies) Len() int {
 }
 
 func (servers *serverLatencies) Less(i, j int) bool {
-	return servers.List[i].Latency < servers.List[j].Latency
+	stats1 := &servers.List[i].LatencyStats
+	stats2 := &servers.List[j].LatencyStats
+	if unreliable1, unreliable2 := stats1.unreliable(), stats2.unreliable(); unreliable1 != unreliable2 {
+		return unreliable2
+	}
+	return stats1.Median < stats2.Median
 }
 
 func (servers *serverLatencies) Swap(i, j int) {
@@ -46,48 +76,178 @@ func (servers *serverLatencies) Swap(i, j int) {
 }
 
 func (server *Server) MeasureLatency(times uint, errorLatency time.Duration) time.Duration {
-	server.client.Log("Measuring server latency...\n")
-	return server.doMeasureLatency(times, errorLatency);
+	latency, _ := server.MeasureLatencyContext(context.Background(), times, errorLatency)
+	return latency
+}
+
+// Measures server latency unless the context is done.
+// Returns the latency averaged over performed measurements, and context error if measurement has been interrupted.
+func (server *Server) MeasureLatencyContext(
+	ctx context.Context,
+	times uint,
+	errorLatency time.Duration) (time.Duration, error) {
+	return server.MeasureLatencyMode(ctx, LatencyWarm, times, errorLatency)
+}
+
+// Measures server latency in the given mode unless the context is done.
+// Returns the latency averaged over performed measurements, and context error if measurement has been interrupted.
+// Latency statistics are recorded per mode, as well as the latest measured ones.
+func (server *Server) MeasureLatencyMode(
+	ctx context.Context,
+	mode LatencyMode,
+	times uint,
+	errorLatency time.Duration) (time.Duration, error) {
+	server.client.Log("Measuring server %s latency...\n", mode)
+	return server.doMeasureLatency(ctx, mode, times, errorLatency);
 }
 
-func (server *Server) doMeasureLatency(times uint, errorLatency time.Duration) time.Duration {
+func (server *Server) doMeasureLatency(
+	ctx context.Context,
+	mode LatencyMode,
+	times uint,
+	errorLatency time.Duration) (time.Duration, error) {
 
-	var results time.Duration = 0
+	samples := make([]time.Duration, 0, times)
+	failed := 0
+	var phases PhaseStats
 	var i uint
 
+	probe := server.latencyProbe(mode)
+	server.prepareLatency(ctx, mode)
 	for i = 0; i < times; i++ {
-		results += server.measureLatency(errorLatency)
+		traceCtx, timing := traceRequest(ctx)
+		latency, err := probe(traceCtx)
+		if ctx.Err() != nil {
+			break // Interrupted measurement is not representative
+		}
+		if mode != LatencyConnect {
+			phases.add(timing())
+		}
+		if err != nil {
+			failed++
+		} else {
+			samples = append(samples, latency)
+		}
 	}
 
-	server.Latency = time.Duration(results / time.Duration(times))
+	server.LatencyStats = NewLatencyStats(samples, failed)
+	server.LatencyPhases = phases
+	if server.ModeLatencies == nil {
+		server.ModeLatencies = make(map[LatencyMode]LatencyStats)
+	}
+	server.ModeLatencies[mode] = server.LatencyStats
+	if len(samples) == 0 {
+		server.Latency = errorLatency
+	} else {
+		server.Latency = server.LatencyStats.Mean
+	}
+
+	return server.Latency, ctx.Err()
+}
 
-	return server.Latency
+// Performs a single latency probe. Returns an error if the probe failed.
+func (server *Server) probeLatency(ctx context.Context) (time.Duration, error) {
+	return server.probeLatencyWith(ctx, server.client.GetContext)
 }
 
-func (server *Server) measureLatency(errorLatency time.Duration) time.Duration {
-	url := server.RelativeURL("latency.txt")
+// Performs a single latency probe using the given function to issue the request.
+func (server *Server) probeLatencyWith(
+	ctx context.Context,
+	get func(ctx context.Context, url string) (*Response, error)) (time.Duration, error) {
+	url, err := server.RelativeURL("latency.txt")
+	if err != nil {
+		server.client.Log("Failed to detect latency: %v\n", err)
+		return 0, err
+	}
 	start := time.Now()
-	resp, err := server.client.Get(url)
+	resp, err := get(ctx, url)
 	duration := time.Since(start);
 	if resp != nil {
 		url = resp.Request.URL.String()
 	}
 	if err != nil {
 		server.client.Log("[%s] Failed to detect latency: %v\n", url, err)
-		return errorLatency
+		return 0, err
 	}
+	content, readErr := resp.ReadContent()
 	if resp.StatusCode != 200 {
 		server.client.Log("[%s] Invalid latency detection HTTP status: %d\n", url, resp.StatusCode)
-		duration = errorLatency
+		err = &HTTPStatusError{URL: url, StatusCode: resp.StatusCode}
 	}
-	content, err := resp.ReadContent()
-	if err != nil {
-		server.client.Log("[%s] Failed to read latency response: %v\n", url, err)
-		duration = errorLatency
+	if readErr != nil {
+		server.client.Log("[%s] Failed to read latency response: %v\n", url, readErr)
+		err = readErr
 	}
 	if !strings.HasPrefix(string(content), "test=test") {
 		server.client.Log("[%s] Invalid latency response: %s\n", url, content)
-		duration = errorLatency
+		err = InvalidLatencyResponseError
+	}
+	return duration, err
+}
+
+var InvalidLatencyResponseError error = errors.New("Invalid latency response")
+
+// Latency statistics.
+type LatencyStats struct {
+	Samples []time.Duration // Successful latency samples in the order of measurement
+	Failed  int             // Number of failed samples
+	Min     time.Duration
+	Max     time.Duration
+	Mean    time.Duration
+	Median  time.Duration
+	StdDev  time.Duration // Standard deviation
+	Jitter  time.Duration // Mean difference between consecutive samples
+}
+
+// Computes statistics of the given latency samples.
+func NewLatencyStats(samples []time.Duration, failed int) LatencyStats {
+	stats := LatencyStats{Samples: samples, Failed: failed}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted) - 1]
+	if mid := len(sorted) / 2; len(sorted) % 2 == 0 {
+		stats.Median = (sorted[mid - 1] + sorted[mid]) / 2
+	} else {
+		stats.Median = sorted[mid]
 	}
-	return duration
+
+	var sum time.Duration
+	for _, sample := range samples {
+		sum += sample
+	}
+	mean := float64(sum) / float64(len(samples))
+	stats.Mean = time.Duration(mean)
+
+	var variance float64
+	for _, sample := range samples {
+		variance += (float64(sample) - mean) * (float64(sample) - mean)
+	}
+	stats.StdDev = time.Duration(math.Sqrt(variance / float64(len(samples))))
+
+	if len(samples) > 1 {
+		var diffs time.Duration
+		for i := 1; i < len(samples); i++ {
+			diff := samples[i] - samples[i - 1]
+			if diff < 0 {
+				diff = -diff
+			}
+			diffs += diff
+		}
+		stats.Jitter = diffs / time.Duration(len(samples) - 1)
+	}
+
+	return stats
+}
+
+// Whether most of the latency probes failed.
+func (stats *LatencyStats) unreliable() bool {
+	return len(stats.Samples) == 0 || stats.Failed > len(stats.Samples)
 }
@@ -1,41 +1,45 @@
 package speedtest
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
 
-func Test_measureLatency(t *testing.T) {
+func Test_probeLatency(t *testing.T) {
 	tests := []struct {
-		name   string
-		client Client
-		input  time.Duration
-		want   time.Duration
+		name    string
+		url     string
+		wantErr string // Error message prefix
 	}{
 		{
-			name:   "Client.Get() error with DefaultErrorLatency input",
-			client: &latencyErrorClient{},
-			input:  DefaultErrorLatency,
-			want:   DefaultErrorLatency,
+			name:    "Client.GetContext() error",
+			url:     "http://speedtest.example.com/speedtest/upload.php",
+			wantErr: "GetContext()",
 		},
 		{
-			name:   "Client.Get() error with 10 Second input",
-			client: &latencyErrorClient{},
-			input:  10 * time.Second,
-			want:   10 * time.Second,
+			name:    "invalid server URL",
+			url:     "http://speedtest.example.com:port/speedtest/upload.php",
+			wantErr: "Invalid server URL `http://speedtest.example.com:port/speedtest/upload.php`",
 		},
 	}
 
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			s := &Server{client: tc.client}
-			if got, want := s.measureLatency(tc.input), tc.want; got != want {
-				t.Fatalf("unexpected result:\n- want: %v\n-  got: %v",
-					want, got)
+			s := &Server{URL: tc.url, client: &latencyErrorClient{}}
+			latency, err := s.probeLatency(context.Background())
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Fatalf("unexpected error:\n- want: %v\n-  got: %v",
+					tc.wantErr, err)
+			}
+			if latency != 0 {
+				t.Errorf("unexpected latency: %v", latency)
 			}
 		})
 	}
@@ -48,21 +52,97 @@ func (c *latencyErrorClient) Log(_ string, _ ...interface{}) {}
 func (c *latencyErrorClient) Config() (*Config, error) {
 	return nil, errors.New("Config()")
 }
+func (c *latencyErrorClient) ConfigContext(_ context.Context) (*Config, error) {
+	return nil, errors.New("ConfigContext()")
+}
 func (c *latencyErrorClient) LoadConfig(_ chan ConfigRef) {}
 func (c *latencyErrorClient) NewRequest(_ string, _ string, _ io.Reader) (*http.Request, error) {
 	return nil, errors.New("NewRequest()")
 }
+func (c *latencyErrorClient) NewRequestContext(_ context.Context, _ string, _ string, _ io.Reader) (*http.Request, error) {
+	return nil, errors.New("NewRequestContext()")
+}
 func (c *latencyErrorClient) Get(_ string) (resp *Response, err error) {
 	return nil, errors.New("Get()")
 }
+func (c *latencyErrorClient) GetContext(_ context.Context, _ string) (resp *Response, err error) {
+	return nil, errors.New("GetContext()")
+}
 func (c *latencyErrorClient) Post(_ string, _ string, _ io.Reader) (*Response, error) {
 	return nil, errors.New("Post()")
 }
+func (c *latencyErrorClient) PostContext(_ context.Context, _ string, _ string, _ io.Reader) (*Response, error) {
+	return nil, errors.New("PostContext()")
+}
+func (c *latencyErrorClient) Do(_ *http.Request) (*Response, error) {
+	return nil, errors.New("Do()")
+}
 func (c *latencyErrorClient) AllServers() (*Servers, error) {
 	return nil, errors.New("AllServers()")
 }
+func (c *latencyErrorClient) AllServersContext(_ context.Context) (*Servers, error) {
+	return nil, errors.New("AllServersContext()")
+}
 func (c *latencyErrorClient) LoadAllServers(_ chan ServersRef) {}
 func (c *latencyErrorClient) ClosestServers() (*Servers, error) {
 	return nil, errors.New("ClosestServers()")
 }
+func (c *latencyErrorClient) ClosestServersContext(_ context.Context) (*Servers, error) {
+	return nil, errors.New("ClosestServersContext()")
+}
 func (c *latencyErrorClient) LoadClosestServers(_ chan ServersRef) {}
+
+func TestNewLatencyStats(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		samples []time.Duration
+		failed  int
+		want    LatencyStats
+	}{
+		{
+			name:   "no samples",
+			failed: 2,
+			want:   LatencyStats{Failed: 2},
+		},
+		{
+			name:    "single sample",
+			samples: []time.Duration{10 * ms},
+			want: LatencyStats{
+				Min:    10 * ms,
+				Max:    10 * ms,
+				Mean:   10 * ms,
+				Median: 10 * ms,
+			},
+		},
+		{
+			name:    "multiple samples",
+			samples: []time.Duration{10 * ms, 30 * ms, 20 * ms, 40 * ms},
+			failed:  1,
+			want: LatencyStats{
+				Failed: 1,
+				Min:    10 * ms,
+				Max:    40 * ms,
+				Mean:   25 * ms,
+				Median: 25 * ms,
+				StdDev: 11180339,
+				Jitter: 16666666,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewLatencyStats(tc.samples, tc.failed)
+			if len(got.Samples) != len(tc.samples) {
+				t.Fatalf("unexpected samples: %v", got.Samples)
+			}
+			got.Samples = nil
+			if want := tc.want; !reflect.DeepEqual(got, want) {
+				t.Fatalf("unexpected result:\n- want: %+v\n-  got: %+v",
+					want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Latency measurement mode.
+type LatencyMode int
+
+const (
+	// HTTP request over an established connection. The connection is established by a request not measured.
+	LatencyWarm LatencyMode = iota
+	// HTTP request over a new connection every time, including DNS lookup, connection setup and TLS handshake.
+	LatencyCold
+	// TCP connection setup only, excluding DNS lookup. Comparable with ICMP-based tools.
+	LatencyConnect
+)
+
+// Latency modes by name.
+var LatencyModes = map[string]LatencyMode{
+	LatencyWarm.String():    LatencyWarm,
+	LatencyCold.String():    LatencyCold,
+	LatencyConnect.String(): LatencyConnect,
+}
+
+func (mode LatencyMode) String() string {
+	switch mode {
+	case LatencyWarm:
+		return "warm"
+	case LatencyCold:
+		return "cold"
+	case LatencyConnect:
+		return "connect"
+	}
+	return "unknown"
+}
+
+// Parses comma-separated list of latency mode names.
+func ParseLatencyModes(names string) ([]LatencyMode, error) {
+	var modes []LatencyMode
+	for _, name := range strings.Split(names, ",") {
+		mode, ok := LatencyModes[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("Unknown latency mode: %s", name)
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
+// Returns sorted names of latency modes.
+func LatencyModeNames() []string {
+	names := make([]string, 0, len(LatencyModes))
+	for name := range LatencyModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns latency probe function for the given mode.
+func (server *Server) latencyProbe(mode LatencyMode) func(ctx context.Context) (time.Duration, error) {
+	switch mode {
+	case LatencyCold:
+		return func(ctx context.Context) (time.Duration, error) {
+			return server.probeLatencyWith(ctx, server.client.(*client).coldGet)
+		}
+	case LatencyConnect:
+		return server.probeConnect
+	}
+	return server.probeLatency
+}
+
+// Prepares latency measurement in the given mode.
+// In warm mode performs a warm-up request establishing the connection reused by the subsequent probes.
+// The warm-up request is not traced, and is not included into latency samples and phase statistics.
+func (server *Server) prepareLatency(ctx context.Context, mode LatencyMode) {
+	if mode == LatencyWarm {
+		server.probeLatency(ctx) // Establish connection
+	}
+}
+
+// Measures the time to establish TCP connection to the server.
+func (server *Server) probeConnect(ctx context.Context) (time.Duration, error) {
+	client := server.client.(*client)
+	req, err := client.NewRequestContext(ctx, "GET", server.URL, nil)
+	if err != nil {
+		return 0, &ServerURLError{URL: server.URL, Err: err}
+	}
+	port := req.URL.Port()
+	if len(port) == 0 {
+		port = "80"
+		if req.URL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	addrs, err := net.DefaultResolver.LookupHost(ctx, req.URL.Hostname())
+	if err != nil {
+		server.client.Log("[%s] Failed to resolve server address: %v\n", req.URL.Hostname(), err)
+		return 0, err
+	}
+
+	address := net.JoinHostPort(addrs[0], port)
+	start := time.Now()
+	conn, err := client.dialer.DialContext(ctx, "tcp", address)
+	duration := time.Since(start)
+	if err != nil {
+		server.client.Log("[%s] Failed to connect: %v\n", address, err)
+		return 0, err
+	}
+	conn.Close()
+
+	return duration, nil
+}
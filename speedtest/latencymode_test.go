@@ -0,0 +1,154 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseLatencyModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []LatencyMode
+		wantErr bool
+	}{
+		{name: "single", input: "cold", want: []LatencyMode{LatencyCold}},
+		{name: "multiple", input: "connect, warm", want: []LatencyMode{LatencyConnect, LatencyWarm}},
+		{name: "unknown", input: "warm,icmp", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseLatencyModes(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// Starts HTTP server responding to latency probes, and counting requests and connections.
+func startLatencyServer() (ts *httptest.Server, requests *int32, connections *int32) {
+	requests, connections = new(int32), new(int32)
+	ts = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		w.Write([]byte("test=test"))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(connections, 1)
+		}
+	}
+	ts.Start()
+	return ts, requests, connections
+}
+
+// Creates speedtest server at the given base URL.
+func newTestServer(t *testing.T, url string) *Server {
+	c, err := NewClient(&Opts{Quiet: true, Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	return &Server{URL: url + "/speedtest/upload.php", client: c}
+}
+
+func TestProbeConnect(t *testing.T) {
+	tests := []struct {
+		name            string
+		closed          bool
+		wantErr         bool
+		wantConnections int32
+	}{
+		{name: "connected", wantConnections: 1},
+		{name: "refused", closed: true, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ts, requests, connections := startLatencyServer()
+			defer ts.Close()
+			if tc.closed {
+				ts.Listener.Close()
+			}
+
+			latency, err := newTestServer(t, ts.URL).probeConnect(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tc.wantErr && latency <= 0 {
+				t.Errorf("unexpected latency: %v", latency)
+			}
+			time.Sleep(10 * time.Millisecond) // Let the server register the connection
+			if got := atomic.LoadInt32(connections); got != tc.wantConnections {
+				t.Errorf("unexpected connections:\n- want: %v\n-  got: %v", tc.wantConnections, got)
+			}
+			if got := atomic.LoadInt32(requests); got != 0 {
+				t.Errorf("unexpected requests:\n- want: %v\n-  got: %v", 0, got)
+			}
+		})
+	}
+}
+
+func TestPrepareLatency(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         LatencyMode
+		wantRequests int32
+	}{
+		{name: "warm", mode: LatencyWarm, wantRequests: 1},
+		{name: "cold", mode: LatencyCold, wantRequests: 0},
+		{name: "connect", mode: LatencyConnect, wantRequests: 0},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ts, requests, _ := startLatencyServer()
+			defer ts.Close()
+
+			newTestServer(t, ts.URL).prepareLatency(context.Background(), tc.mode)
+
+			if got := atomic.LoadInt32(requests); got != tc.wantRequests {
+				t.Errorf("unexpected requests:\n- want: %v\n-  got: %v", tc.wantRequests, got)
+			}
+		})
+	}
+}
+
+func TestWarmLatencyExcludesWarmUp(t *testing.T) {
+	ts, requests, connections := startLatencyServer()
+	defer ts.Close()
+
+	server := newTestServer(t, ts.URL)
+	if _, err := server.MeasureLatencyMode(context.Background(), LatencyWarm, 3, DefaultErrorLatency); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		got  int
+		want int
+	}{
+		{name: "requests", got: int(atomic.LoadInt32(requests)), want: 4},
+		{name: "connections", got: int(atomic.LoadInt32(connections)), want: 1},
+		{name: "samples", got: len(server.LatencyStats.Samples), want: 3},
+		{name: "traced requests", got: server.LatencyPhases.Requests, want: 3},
+		{name: "reused connections", got: server.LatencyPhases.Reused, want: 3},
+		{name: "connect phases", got: server.LatencyPhases.Connect.Count, want: 0},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("unexpected %s:\n- want: %v\n-  got: %v",
+				tc.name, tc.want, tc.got)
+		}
+	}
+}
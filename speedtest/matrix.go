@@ -0,0 +1,109 @@
+package speedtest
+
+import (
+	"context"
+	"sort"
+)
+
+// Outcome of tests performed against multiple servers one after another.
+type Matrix struct {
+	Entries  []*MatrixEntry
+	Latency  MatrixStats // Latency in nanoseconds. The best is the lowest one
+	Download MatrixStats // Download speed in bytes per second
+	Upload   MatrixStats // Upload speed in bytes per second
+}
+
+// Outcome of tests performed against a single server of the matrix.
+type MatrixEntry struct {
+	Server   *Server
+	Download *Measurement // Nil if the test has not been performed
+	Upload   *Measurement // Nil if the test has not been performed
+}
+
+// Aggregate of the values measured against all servers of the matrix.
+type MatrixStats struct {
+	Count  int // Number of servers measured
+	Best   float64
+	Median float64
+}
+
+// Measures latency in the given mode, then download and upload speed with the given test profile
+// against each server one after another unless the context is done.
+// When grading bufferbloat, warm latency is measured as well to grade against.
+// Payload sizes are selected per server by line speed according to the given thresholds, if any.
+// Returns the matrix of results, and context error if tests have been interrupted.
+func (servers *Servers) TestMatrix(
+	ctx context.Context,
+	mode LatencyMode,
+	times ConfigTimes,
+	profile *TestProfile) (*Matrix, error) {
+	matrix := &Matrix{}
+	for _, server := range servers.List {
+		entry := &MatrixEntry{Server: server}
+		matrix.Entries = append(matrix.Entries, entry)
+
+		server.client.Log("Testing against %s (%s) [%s]...", server.Sponsor, server.Name, server.DistanceString())
+		if server.client.(*client).opts.Bufferbloat && mode != LatencyWarm {
+			if _, err := server.MeasureLatencyMode(ctx, LatencyWarm, DefaultLatencyMeasureTimes, DefaultErrorLatency); err != nil {
+				break
+			}
+		}
+		if _, err := server.MeasureLatencyMode(ctx, mode, DefaultLatencyMeasureTimes, DefaultErrorLatency); err != nil {
+			break
+		}
+		sized, err := server.SelectPayloadSizes(ctx, times, profile)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			server.client.Log("Failed to estimate line speed, using all payload sizes: %v", err)
+		}
+		entry.Download = server.DownloadProfile(ctx, sized)
+		if entry.Download.Err != nil {
+			break
+		}
+		entry.Upload = server.UploadProfile(ctx, sized)
+		if entry.Upload.Err != nil {
+			break
+		}
+	}
+	matrix.aggregate()
+	return matrix, ctx.Err()
+}
+
+func (matrix *Matrix) aggregate() {
+	var latencies, downloads, uploads []float64
+	for _, entry := range matrix.Entries {
+		if !entry.Server.LatencyStats.unreliable() {
+			latencies = append(latencies, float64(entry.Server.Latency))
+		}
+		if entry.Download != nil && entry.Download.Err == nil {
+			downloads = append(downloads, float64(entry.Download.Speed()))
+		}
+		if entry.Upload != nil && entry.Upload.Err == nil {
+			uploads = append(uploads, float64(entry.Upload.Speed()))
+		}
+	}
+	matrix.Latency = newMatrixStats(latencies, false)
+	matrix.Download = newMatrixStats(downloads, true)
+	matrix.Upload = newMatrixStats(uploads, true)
+}
+
+func newMatrixStats(values []float64, highest bool) MatrixStats {
+	stats := MatrixStats{Count: len(values)}
+	if len(values) == 0 {
+		return stats
+	}
+	sort.Float64s(values)
+	if highest {
+		stats.Best = values[len(values)-1]
+	} else {
+		stats.Best = values[0]
+	}
+	if mid := len(values) / 2; len(values)%2 == 0 {
+		stats.Median = (values[mid-1] + values[mid]) / 2
+	} else {
+		stats.Median = values[mid]
+	}
+	return stats
+}
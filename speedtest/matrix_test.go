@@ -0,0 +1,135 @@
+package speedtest
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatrix_aggregate(t *testing.T) {
+	reliable := LatencyStats{Samples: []time.Duration{time.Millisecond}}
+	matrix := &Matrix{Entries: []*MatrixEntry{
+		{
+			Server:   &Server{Latency: 30 * time.Millisecond, LatencyStats: reliable},
+			Download: &Measurement{Bytes: 3000, Duration: time.Second},
+			Upload:   &Measurement{Bytes: 100, Duration: time.Second},
+		},
+		{
+			Server:   &Server{Latency: 10 * time.Millisecond, LatencyStats: reliable},
+			Download: &Measurement{Bytes: 1000, Duration: time.Second},
+			Upload:   &Measurement{Bytes: 300, Duration: time.Second},
+		},
+		{
+			Server:   &Server{Latency: 20 * time.Millisecond, LatencyStats: reliable},
+			Download: &Measurement{Bytes: 2000, Duration: time.Second},
+			Upload:   &Measurement{Bytes: 200, Duration: time.Second, Err: context.Canceled},
+		},
+		{
+			Server: &Server{Latency: DefaultErrorLatency},
+		},
+	}}
+	matrix.aggregate()
+
+	for _, tc := range []struct {
+		name string
+		got  MatrixStats
+		want MatrixStats
+	}{
+		{name: "latency", got: matrix.Latency, want: MatrixStats{Count: 3, Best: 10e6, Median: 20e6}},
+		{name: "download", got: matrix.Download, want: MatrixStats{Count: 3, Best: 3000, Median: 2000}},
+		{name: "upload", got: matrix.Upload, want: MatrixStats{Count: 2, Best: 300, Median: 200}},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("unexpected %s:\n- want: %+v\n-  got: %+v", tc.name, tc.want, tc.got)
+		}
+	}
+}
+
+func TestServers_TestMatrix(t *testing.T) {
+	var mutex sync.Mutex
+	images := make(map[string]bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path.Ext(r.URL.Path) {
+		case ".txt":
+			w.Write([]byte("test=test"))
+		case ".jpg":
+			mutex.Lock()
+			images[path.Base(r.URL.Path)] = true
+			mutex.Unlock()
+			w.Write(make([]byte, 1000))
+		default:
+			io.Copy(ioutil.Discard, r.Body)
+			w.Write([]byte("size=1000"))
+		}
+	}))
+	defer ts.Close()
+
+	profile := &TestProfile{
+		Duration:        10 * time.Second,
+		Streams:         2,
+		MaxStreams:      2,
+		DownloadSizes:   []int{350, 500, 750, 1000},
+		DownloadRepeats: 1,
+		UploadSizes:     []int{1000},
+		UploadRepeats:   1,
+		BufferSize:      4096,
+	}
+
+	tests := []struct {
+		name  string
+		times ConfigTimes
+		want  []string
+	}{
+		{
+			name: "all sizes",
+			want: []string{"random1000x1000.jpg", "random350x350.jpg", "random500x500.jpg", "random750x750.jpg"},
+		},
+		{
+			name:  "sized by line speed",
+			times: ConfigTimes{{Download: 1, Upload: 1}},
+			want:  []string{"random1000x1000.jpg", "random500x500.jpg", "random750x750.jpg"}, // Including the probe
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mutex.Lock()
+			images = make(map[string]bool)
+			mutex.Unlock()
+
+			c, err := NewClient(&Opts{Quiet: true, Timeout: 10 * time.Second})
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			servers := &Servers{List: []*Server{{ID: 1, URL: ts.URL + "/speedtest/upload.php", client: c}}}
+
+			matrix, err := servers.TestMatrix(context.Background(), LatencyWarm, tc.times, profile)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := matrix.Download.Count + matrix.Upload.Count; got != 2 {
+				t.Errorf("unexpected measurements:\n- want: %v\n-  got: %v", 2, got)
+			}
+
+			var got []string
+			mutex.Lock()
+			for image := range images {
+				got = append(got, image)
+			}
+			mutex.Unlock()
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package speedtest
+
+import (
+	"sort"
+	"time"
+)
+
+// Measurement describes the outcome of a single throughput test.
+type Measurement struct {
+	Bytes     int64                 // Total number of payload bytes transferred
+	WireBytes int64                 // Raw bytes transferred over network connections, including HTTP and TLS framing
+	Duration  time.Duration         // Elapsed time of the test
+	Started   int                   // Number of requests started
+	Completed int                   // Number of requests completed successfully
+	Failed    int                   // Number of failed requests
+	Streams   int                   // Maximum number of simultaneous requests
+	Payloads  []*PayloadMeasurement // Breakdown by payload size, ordered by size
+	Servers   []*ServerMeasurement  // Breakdown by server, in order of the first request
+	Errors    []error               // Errors encountered during the test
+	Err       error                 // Error preventing the test from completion, e.g. context error
+
+	Phases PhaseStats // HTTP request phases
+
+	Samples    []Sample        // Cumulative number of bytes transferred, sampled at regular intervals
+	Throughput ThroughputStats // Throughput statistics derived from samples
+
+	// Latency measured while the test was running. Only measured when Bufferbloat option is set.
+	LoadedLatency *LatencyStats
+}
+
+// Part of the measurement related to particular payload size.
+type PayloadMeasurement struct {
+	Size      int   // Payload size. Image dimension for download, and number of bytes for upload
+	Bytes     int64 // Number of bytes transferred
+	Started   int   // Number of requests started
+	Completed int   // Number of requests completed successfully
+	Failed    int   // Number of failed requests
+}
+
+// Part of the measurement related to particular server.
+type ServerMeasurement struct {
+	Server    *Server
+	Bytes     int64 // Number of bytes transferred
+	Started   int   // Number of requests started
+	Completed int   // Number of requests completed successfully
+	Failed    int   // Number of failed requests
+}
+
+// Returns the fraction of bytes transferred to or from this server.
+func (s *ServerMeasurement) Share(m *Measurement) float64 {
+	if m.Bytes <= 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(m.Bytes)
+}
+
+// Speed returns the average transfer rate in bytes per second.
+func (m *Measurement) Speed() int {
+	if m.Duration <= 0 {
+		return 0
+	}
+	return int(m.Bytes * int64(time.Second) / int64(m.Duration))
+}
+
+// Returns the average raw transfer rate over network connections in bytes per second.
+func (m *Measurement) WireSpeed() int {
+	if m.Duration <= 0 {
+		return 0
+	}
+	return int(m.WireBytes * int64(time.Second) / int64(m.Duration))
+}
+
+// Returns the protocol overhead as percentage of raw bytes transferred over network connections.
+func (m *Measurement) Overhead() float64 {
+	if m.WireBytes <= 0 {
+		return 0
+	}
+	return float64(m.WireBytes-m.Bytes) * 100 / float64(m.WireBytes)
+}
+
+// Returns the part of measurement related to the given payload size, creating it if necessary.
+func (m *Measurement) payload(size int) *PayloadMeasurement {
+	i := sort.Search(len(m.Payloads), func(i int) bool {
+		return m.Payloads[i].Size >= size
+	})
+	if i < len(m.Payloads) && m.Payloads[i].Size == size {
+		return m.Payloads[i]
+	}
+	payload := &PayloadMeasurement{Size: size}
+	m.Payloads = append(m.Payloads, nil)
+	copy(m.Payloads[i + 1:], m.Payloads[i:])
+	m.Payloads[i] = payload
+	return payload
+}
+
+// Returns the part of measurement related to the given server, creating it if necessary.
+func (m *Measurement) server(server *Server) *ServerMeasurement {
+	for _, s := range m.Servers {
+		if s.Server == server {
+			return s
+		}
+	}
+	s := &ServerMeasurement{Server: server}
+	m.Servers = append(m.Servers, s)
+	return s
+}
+
+func (m *Measurement) add(t transfer) {
+	payload := m.payload(t.size)
+	server := m.server(t.server)
+	m.Bytes += t.bytes
+	payload.Bytes += t.bytes
+	server.Bytes += t.bytes
+	if !t.started {
+		return
+	}
+	m.Started++
+	payload.Started++
+	server.Started++
+	m.Phases.add(t.timing)
+	if t.err != nil {
+		m.Failed++
+		payload.Failed++
+		server.Failed++
+		m.Errors = append(m.Errors, t.err)
+	} else {
+		m.Completed++
+		payload.Completed++
+		server.Completed++
+	}
+}
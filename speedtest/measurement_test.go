@@ -0,0 +1,201 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMeasure(t *testing.T) {
+	tasks := []transferTask{
+		{url: "a", size: 500},
+		{url: "b", size: 350},
+		{url: "fail", size: 350},
+		{url: "late", size: 500},
+	}
+	transferFile := func(_ context.Context, task transferTask, run *transferRun, ret chan transfer) {
+		switch task.url {
+		case "fail":
+			ret <- transfer{size: task.size, bytes: 10, started: true, err: errors.New("failed")}
+		case "late":
+			ret <- transfer{size: task.size}
+		default:
+			run.startRequest()
+			run.addBytes(int64(task.size))
+			ret <- transfer{size: task.size, bytes: int64(task.size), started: true}
+		}
+	}
+
+	var last Progress
+	opts := &Opts{Progress: func(progress Progress) { last = progress }}
+
+	c := &client{opts: opts}
+	config := &transferConfig{phase: PhaseUpload, tasks: tasks, duration: time.Second, streams: 2, maxStreams: 2}
+	m := c.measure(context.Background(), config, transferFile)
+
+	if !last.Done || last.Phase != PhaseUpload || last.Bytes != 850 || last.Requests != 2 {
+		t.Errorf("unexpected final progress: %+v", last)
+	}
+
+	for _, tc := range []struct {
+		name string
+		got  int64
+		want int64
+	}{
+		{name: "bytes", got: m.Bytes, want: 860},
+		{name: "started", got: int64(m.Started), want: 3},
+		{name: "completed", got: int64(m.Completed), want: 2},
+		{name: "failed", got: int64(m.Failed), want: 1},
+		{name: "streams", got: int64(m.Streams), want: 2},
+		{name: "errors", got: int64(len(m.Errors)), want: 1},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("unexpected %s:\n- want: %v\n-  got: %v",
+				tc.name, tc.want, tc.got)
+		}
+	}
+
+	wantPayloads := []PayloadMeasurement{
+		{Size: 350, Bytes: 360, Started: 2, Completed: 1, Failed: 1},
+		{Size: 500, Bytes: 500, Started: 1, Completed: 1},
+	}
+	if len(m.Payloads) != len(wantPayloads) {
+		t.Fatalf("unexpected payloads count: %d", len(m.Payloads))
+	}
+	for i, want := range wantPayloads {
+		if got := *m.Payloads[i]; got != want {
+			t.Errorf("unexpected payload %d:\n- want: %+v\n-  got: %+v",
+				i, want, got)
+		}
+	}
+}
+
+func TestMeasureCancel(t *testing.T) {
+	started := make(chan struct{}, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&Opts{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	client := c.(*client)
+
+	tests := []struct {
+		name         string
+		phase        Phase
+		transferFile func(ctx context.Context, task transferTask, run *transferRun, ret chan transfer)
+	}{
+		{name: "download", phase: PhaseDownload, transferFile: client.downloadFile},
+		{name: "upload", phase: PhaseUpload, transferFile: client.uploadFile},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tasks := []transferTask{
+				{url: ts.URL + "/fast", size: 1000},
+				{url: ts.URL + "/fast", size: 1000},
+				{url: ts.URL + "/slow", size: 1000},
+				{url: ts.URL + "/slow", size: 1000},
+				{url: ts.URL + "/late", size: 1000},
+			}
+			config := &transferConfig{
+				phase:      tc.phase,
+				tasks:      tasks,
+				duration:   10 * time.Second,
+				streams:    2,
+				maxStreams: 2,
+				bufferSize: 4096,
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				<-started
+				<-started
+				cancel()
+			}()
+
+			m := client.measure(ctx, config, tc.transferFile)
+
+			if !errors.Is(m.Err, context.Canceled) {
+				t.Errorf("unexpected measurement error: %v", m.Err)
+			}
+			for _, err := range m.Errors {
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("unexpected transfer error: %v", err)
+				}
+			}
+			for _, result := range []struct {
+				name string
+				got  int
+				want int
+			}{
+				{name: "started", got: m.Started, want: 4},
+				{name: "completed", got: m.Completed, want: 2},
+				{name: "failed", got: m.Failed, want: 2},
+				{name: "errors", got: len(m.Errors), want: 2},
+			} {
+				if result.got != result.want {
+					t.Errorf("unexpected %s:\n- want: %v\n-  got: %v",
+						result.name, result.want, result.got)
+				}
+			}
+		})
+	}
+}
+
+func TestMeasurementServers(t *testing.T) {
+	server1 := &Server{ID: 1}
+	server2 := &Server{ID: 2}
+
+	m := &Measurement{}
+	for _, transfer := range []transfer{
+		{server: server1, size: 500, bytes: 500, started: true},
+		{server: server2, size: 500, bytes: 300, started: true},
+		{server: server1, size: 350, bytes: 100, started: true, err: errors.New("failed")},
+		{server: server2, size: 350},
+		{server: server1, size: 350, bytes: 350, started: true},
+	} {
+		m.add(transfer)
+	}
+
+	want := []ServerMeasurement{
+		{Server: server1, Bytes: 950, Started: 3, Completed: 2, Failed: 1},
+		{Server: server2, Bytes: 300, Started: 1, Completed: 1},
+	}
+	if len(m.Servers) != len(want) {
+		t.Fatalf("unexpected servers count: %d", len(m.Servers))
+	}
+	for i, want := range want {
+		if got := *m.Servers[i]; got != want {
+			t.Errorf("unexpected server %d:\n- want: %+v\n-  got: %+v",
+				i, want, got)
+		}
+	}
+
+	for i, want := range []float64{0.76, 0.24} {
+		if got := m.Servers[i].Share(m); math.Abs(got-want) > 1e-9 {
+			t.Errorf("unexpected share of server %d:\n- want: %v\n-  got: %v",
+				i, want, got)
+		}
+	}
+	if got := (&ServerMeasurement{}).Share(&Measurement{}); got != 0 {
+		t.Errorf("unexpected share of empty measurement: %v", got)
+	}
+}
@@ -0,0 +1,88 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var InvalidMiniServerError error = errors.New("Invalid speedtest Mini server")
+
+var miniExtensions = [...]string{"php", "asp", "aspx", "jsp"}
+
+var miniExtensionPattern = regexp.MustCompile(`upload_?[Ee]xtension: "([^"]+)"`)
+var miniUploadPattern = regexp.MustCompile(`^size=[0-9]`)
+
+// Creates a server representing the speedtest Mini installation at the given URL.
+// The upload handler extension is detected either from the Mini page, or by probing the known handlers.
+func NewMiniServer(client Client, miniURL string) (*Server, error) {
+	return NewMiniServerContext(context.Background(), client, miniURL)
+}
+
+// Creates a server representing the speedtest Mini installation at the given URL unless the context is done.
+// The upload handler extension is detected either from the Mini page, or by probing the known handlers.
+func NewMiniServerContext(ctx context.Context, client Client, miniURL string) (*Server, error) {
+	u, err := url.Parse(miniURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(u.Host) == 0 {
+		return nil, InvalidMiniServerError
+	}
+	if len(path.Ext(u.Path)) != 0 {
+		u.Path = path.Dir(u.Path)
+	}
+	base := strings.TrimRight(u.String(), "/")
+
+	resp, err := client.GetContext(ctx, base + "/")
+	if err != nil {
+		return nil, err
+	}
+	content, err := resp.ReadContent()
+	if err != nil {
+		return nil, err
+	}
+
+	var extension string
+	if match := miniExtensionPattern.FindSubmatch(content); match != nil {
+		extension = string(match[1])
+	} else {
+		for _, ext := range miniExtensions {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if probeMiniUpload(ctx, client, base + "/speedtest/upload." + ext) {
+				extension = ext
+				break
+			}
+		}
+	}
+	if len(extension) == 0 {
+		return nil, InvalidMiniServerError
+	}
+
+	return &Server{
+		URL:      base + "/speedtest/upload." + extension,
+		Name:     u.Host,
+		Sponsor:  "Speedtest Mini",
+		Host:     u.Host,
+		Distance: UnknownDistance,
+		client:   client,
+	}, nil
+}
+
+func probeMiniUpload(ctx context.Context, client Client, url string) bool {
+	resp, err := client.GetContext(ctx, url)
+	if err != nil {
+		return false
+	}
+	content, err := resp.ReadContent()
+	if err != nil || resp.StatusCode != 200 {
+		return false
+	}
+	data := strings.TrimSpace(string(content))
+	return !strings.Contains(data, "\n") && miniUploadPattern.MatchString(data)
+}
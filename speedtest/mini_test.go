@@ -0,0 +1,122 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewMiniServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "extension in page",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`<script>var settings = {uploadExtension: "aspx"};</script>`))
+			},
+			path: "/mini/index.html",
+			want: "/mini/speedtest/upload.aspx",
+		},
+		{
+			name: "extension probed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/speedtest/upload.jsp" {
+					w.Write([]byte("size=0\n"))
+				}
+			},
+			path: "/",
+			want: "/speedtest/upload.jsp",
+		},
+		{
+			name:    "not a mini server",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			path:    "/",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(tc.handler)
+			defer ts.Close()
+
+			c, err := NewClient(&Opts{Timeout: 10 * time.Second})
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			s, err := NewMiniServer(c, ts.URL + tc.path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got server %v", s)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := s.URL, ts.URL + tc.want; got != want {
+				t.Fatalf("unexpected URL:\n- want: %v\n-  got: %v",
+					want, got)
+			}
+			if s.HasDistance() {
+				t.Fatalf("unexpected distance: %v", s.DistanceString())
+			}
+			if got, want := relativeURL(t, s, "latency.txt"), ts.URL + path.Dir(tc.want) + "/latency.txt"; got != want {
+				t.Fatalf("unexpected relative URL:\n- want: %v\n-  got: %v",
+					want, got)
+			}
+		})
+	}
+}
+
+func relativeURL(t *testing.T, s *Server, local string) string {
+	u, err := s.RelativeURL(local)
+	if err != nil {
+		t.Fatalf("unexpected relative URL error: %v", err)
+	}
+	return u
+}
+
+func TestNewMiniServerContextCancel(t *testing.T) {
+	var probes int32
+	started := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			return // No extension in page
+		}
+		if atomic.AddInt32(&probes, 1) == 1 {
+			close(started)
+		}
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&Opts{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	s, err := NewMiniServerContext(ctx, c, ts.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected server %v, error: %v", s, err)
+	}
+	if got := atomic.LoadInt32(&probes); got != 1 {
+		t.Errorf("unexpected probes:\n- want: %v\n-  got: %v", 1, got)
+	}
+}
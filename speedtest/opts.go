@@ -2,19 +2,50 @@ package speedtest
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
 	"time"
 )
 
+// Maximum number of servers to test against simultaneously. Equals to the number of closest servers selected.
+const maxParallel = 5
+
 type Opts struct {
-	SpeedInBytes bool
-	Quiet        bool
-	List         bool
-	Server       ServerID
-	Interface    string
-	Timeout      time.Duration
-	Secure       bool
-	Help         bool
-	Version      bool
+	SpeedInBytes   bool
+	Quiet          bool
+	Verbose        bool
+	List           bool
+	Server         ServerID
+	Servers        []ServerID // All server IDs specified, the first of which is Server
+	Top            int
+	Parallel       int
+	Filter         *ServerFilter  // Applied to closest servers and server list
+	ServerSources  []ServerSource // Server lists to use in addition to speedtest.net ones
+	NoDefaultServers bool         // Use ServerSources only
+	Mini           string
+	Interface      string
+	Timeout        time.Duration
+	LatencyModes   []LatencyMode // The first one is used for server selection
+	SampleInterval time.Duration
+	Profile        *TestProfile
+	FixedSizes     bool
+	Secure         bool
+	CacheTTL       time.Duration
+	Refresh        bool
+	Bufferbloat    bool
+	Share          bool
+	ShareURL       string
+	JSON           bool
+	CSV            bool
+	CSVHeader      bool
+	CSVDelimiter   string
+	Listen         string
+	MetricsTTL     time.Duration
+	Help           bool
+	Version        bool
+	Progress       ProgressFunc // Throughput test progress observer
 }
 
 func ParseOpts() *Opts {
@@ -23,17 +54,147 @@ func ParseOpts() *Opts {
 	flag.BoolVar(&opts.SpeedInBytes, "bytes", false,
 		"Display values in bytes instead of bits. Does not affect the image generated by -share")
 	flag.BoolVar(&opts.Quiet, "quiet", false, "Suppress verbose output, only show basic information")
+	flag.BoolVar(&opts.Verbose, "verbose", false,
+		"Show detailed report, including timings of DNS lookup, connection setup, TLS handshake and first byte")
 	flag.BoolVar(&opts.List, "list", false, "Display a list of speedtest.net servers sorted by distance")
-	flag.Uint64Var((*uint64)(&opts.Server), "server", 0, "Specify a server ID to test against")
+	flag.Func("server",
+		"Specify a server ID to test against, or comma-separated server IDs to test against each of them " +
+			"and report the matrix of results",
+		func(value string) (err error) {
+			opts.Servers, err = ParseServerIDs(value)
+			if err == nil && len(opts.Servers) != 0 {
+				opts.Server = opts.Servers[0]
+			}
+			return err
+		})
+	flag.IntVar(&opts.Top, "top", 0,
+		"Test against each of the given number of closest servers and report the matrix of results")
+	flag.Func("server-list",
+		"URL or path of the file containing server list in speedtest.net XML or JSON format to use in addition " +
+			"to speedtest.net servers. May be specified multiple times",
+		func(value string) error {
+			opts.ServerSources = append(opts.ServerSources, ParseServerSource(value))
+			return nil
+		})
+	flag.BoolVar(&opts.NoDefaultServers, "no-default-servers", false,
+		"Do not use speedtest.net server list, only the ones specified with -server-list option")
+	opts.Filter = new(ServerFilter)
+	flag.StringVar(&opts.Filter.Country, "country", "", "Only use servers in the given country, specified by ISO code, e.g. DE")
+	flag.Func("sponsor", "Only use servers with sponsor or name matching the given regular expression",
+		func(value string) (err error) {
+			opts.Filter.Sponsor, err = regexp.Compile(value)
+			return err
+		})
+	flag.Func("host", "Only use servers with host matching the given regular expression",
+		func(value string) (err error) {
+			opts.Filter.Host, err = regexp.Compile(value)
+			return err
+		})
+	flag.Float64Var(&opts.Filter.MaxDistance, "max-distance", 0, "Only use servers within the given distance in kilometers")
+	flag.Func("include", "Only use servers with the given comma-separated IDs", func(value string) (err error) {
+		opts.Filter.Include, err = ParseServerIDs(value)
+		return err
+	})
+	flag.Func("exclude", "Never use servers with the given comma-separated IDs", func(value string) (err error) {
+		opts.Filter.Exclude, err = ParseServerIDs(value)
+		return err
+	})
+	flag.IntVar(&opts.Parallel, "parallel", 0,
+		fmt.Sprintf("Test against the given number of servers with the lowest latency simultaneously, up to %d, ", maxParallel) +
+			"and report aggregate speed along with each server's share")
+	flag.StringVar(&opts.Mini, "mini", "", "URL of the Speedtest Mini server")
 	flag.StringVar(&opts.Interface, "interface", "", "IP address of network interface to bind to")
 	flag.DurationVar(&opts.Timeout, "timeout", 10 * time.Second, "HTTP timeout duration. Default 10s")
+	latencyModes := flag.String("latency-mode", LatencyWarm.String(),
+		"Comma-separated latency measurement modes, any of: " + strings.Join(LatencyModeNames(), ", ") + ". " +
+			"The first one is used for server selection, and the others are reported separately")
+	profileName := flag.String("profile", StandardProfile.Name,
+		"Test profile, one of: " + strings.Join(ProfileNames(), ", "))
+	profile := new(TestProfile)
+	flag.DurationVar(&profile.Duration, "duration", 0,
+		"Maximum duration of each of download and upload tests. Overrides test profile")
+	flag.IntVar(&profile.Streams, "streams", 0,
+		"Number of simultaneous download and upload streams, or initial one with -adaptive option. Overrides test profile")
+	flag.BoolVar(&profile.Adaptive, "adaptive", false,
+		"Add download and upload streams while throughput keeps rising, instead of using a fixed number of streams. " +
+			"Overrides test profile")
+	flag.IntVar(&profile.MaxStreams, "max-streams", 0,
+		"Maximum number of streams used with -adaptive option, at least 2. Overrides test profile")
+	flag.IntVar(&profile.BufferSize, "buffer-size", 0, "Download buffer size in bytes. Overrides test profile")
+	flag.BoolVar(&opts.FixedSizes, "fixed-sizes", false,
+		"Use all payload sizes of the test profile instead of selecting them by line speed estimated with a short probe")
+	flag.DurationVar(&opts.SampleInterval, "sample-interval", DefaultSampleInterval,
+		"Interval of sampling the throughput during download and upload tests")
 	flag.BoolVar(&opts.Secure, "secure", false,
 		"Use HTTPS instead of HTTP when communicating with speedtest.net operated servers")
+	flag.DurationVar(&opts.CacheTTL, "cache-ttl", DefaultCacheTTL,
+		"How long to use speedtest.net configuration and server list cached on disk. Zero disables caching")
+	flag.BoolVar(&opts.Refresh, "refresh", false,
+		"Retrieve speedtest.net configuration and server list even if cached ones are still valid")
+	flag.BoolVar(&opts.Bufferbloat, "bufferbloat", false,
+		"Measure latency under load during download and upload tests and grade bufferbloat")
+	flag.BoolVar(&opts.Share, "share", false, "Generate and provide a URL to the speedtest.net share results image")
+	flag.StringVar(&opts.ShareURL, "share-url", DefaultShareURL, "speedtest.net result submission API URL")
+	flag.BoolVar(&opts.JSON, "json", false, "Output results in JSON format")
+	flag.BoolVar(&opts.CSV, "csv", false, "Output results in CSV format")
+	flag.BoolVar(&opts.CSVHeader, "csv-header", false, "Print CSV headers and exit")
+	flag.StringVar(&opts.CSVDelimiter, "csv-delimiter", ",", "Single character delimiter to use in CSV output")
+	flag.StringVar(&opts.Listen, "listen", "", "Run as Prometheus exporter serving metrics at the given address, e.g. :9696")
+	flag.DurationVar(&opts.MetricsTTL, "metrics-ttl", 5 * time.Minute,
+		"Minimal interval between speed tests run by Prometheus exporter")
 	flag.BoolVar(&opts.Help, "help", false, "Show usage information and exit")
 	flag.BoolVar(&opts.Help, "h", false, "Shorthand for -help option")
 	flag.BoolVar(&opts.Version, "version", false, "Show the version number and exit")
 
 	flag.Parse();
 
+	preset, ok := Profiles[*profileName]
+	if !ok {
+		fmt.Fprintf(flag.CommandLine.Output(), "Unknown test profile: %s\n", *profileName)
+		os.Exit(2)
+	}
+	if opts.Parallel < 0 || opts.Parallel > maxParallel {
+		fmt.Fprintf(flag.CommandLine.Output(), "Invalid number of parallel servers: %d\n", opts.Parallel)
+		os.Exit(2)
+	}
+
+	if opts.NoDefaultServers && len(opts.ServerSources) == 0 {
+		fmt.Fprintln(flag.CommandLine.Output(), "Option -no-default-servers requires -server-list")
+		os.Exit(2)
+	}
+
+	opts.Profile = preset.override(profile, flag.CommandLine)
+	if opts.Profile.MaxStreams < 2 {
+		fmt.Fprintf(flag.CommandLine.Output(), "Invalid maximum number of streams: %d\n", opts.Profile.MaxStreams)
+		os.Exit(2)
+	}
+
+	modes, err := ParseLatencyModes(*latencyModes)
+	if err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(2)
+	}
+	opts.LatencyModes = modes
+
 	return opts
 }
+
+// Returns a copy of the profile with fields overridden by the ones explicitly set on command line.
+func (profile *TestProfile) override(overrides *TestProfile, flags *flag.FlagSet) *TestProfile {
+	result := *profile
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "duration":
+			result.Duration = overrides.Duration
+		case "streams":
+			result.Streams = overrides.Streams
+		case "adaptive":
+			result.Adaptive = overrides.Adaptive
+		case "max-streams":
+			result.MaxStreams = overrides.MaxStreams
+		case "buffer-size":
+			result.BufferSize = overrides.BufferSize
+		}
+	})
+	return &result
+}
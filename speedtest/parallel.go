@@ -0,0 +1,66 @@
+package speedtest
+
+import (
+	"context"
+)
+
+// Performs a download test against all servers simultaneously with the given test profile
+// unless the context is done. Streams are split between servers. The measurement is broken down by server.
+// When interrupted, returns the partial measurement with the context error.
+func (servers *Servers) DownloadParallel(ctx context.Context, profile *TestProfile) *Measurement {
+	if servers.Len() == 0 {
+		return &Measurement{Err: NoServersError}
+	}
+	perServer := make([][]transferTask, 0, servers.Len())
+	for _, server := range servers.List {
+		tasks, err := server.downloadTasks(profile)
+		if err != nil {
+			return &Measurement{Err: err}
+		}
+		perServer = append(perServer, tasks)
+	}
+	client := servers.List[0].client.(*client)
+	return client.measure(ctx, servers.parallelConfig(profile, PhaseDownload, perServer), client.downloadFile)
+}
+
+// Performs an upload test against all servers simultaneously with the given test profile
+// unless the context is done. Streams are split between servers. The measurement is broken down by server.
+// When interrupted, returns the partial measurement with the context error.
+func (servers *Servers) UploadParallel(ctx context.Context, profile *TestProfile) *Measurement {
+	if servers.Len() == 0 {
+		return &Measurement{Err: NoServersError}
+	}
+	perServer := make([][]transferTask, 0, servers.Len())
+	for _, server := range servers.List {
+		perServer = append(perServer, server.uploadTasks(profile))
+	}
+	client := servers.List[0].client.(*client)
+	return client.measure(ctx, servers.parallelConfig(profile, PhaseUpload, perServer), client.uploadFile)
+}
+
+// Builds throughput test configuration with tasks of all servers interleaved,
+// so that simultaneous streams are evenly split between servers.
+func (servers *Servers) parallelConfig(profile *TestProfile, phase Phase, perServer [][]transferTask) *transferConfig {
+	var tasks []transferTask
+	for i := 0; ; i++ {
+		added := false
+		for _, serverTasks := range perServer {
+			if i < len(serverTasks) {
+				tasks = append(tasks, serverTasks[i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	config := profile.transferConfig(phase, tasks)
+	if config.streams < servers.Len() {
+		config.streams = servers.Len() // At least one stream per server
+	}
+	if config.maxStreams < config.streams {
+		config.maxStreams = config.streams
+	}
+	return config
+}
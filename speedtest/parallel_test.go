@@ -0,0 +1,32 @@
+package speedtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestServers_parallelConfig(t *testing.T) {
+	server1 := &Server{ID: 1}
+	server2 := &Server{ID: 2}
+	servers := &Servers{List: []*Server{server1, server2}}
+	perServer := [][]transferTask{
+		{{server: server1, size: 1}, {server: server1, size: 2}, {server: server1, size: 3}},
+		{{server: server2, size: 1}},
+	}
+	profile := &TestProfile{Streams: 1}
+
+	config := servers.parallelConfig(profile, PhaseDownload, perServer)
+
+	want := []transferTask{
+		{server: server1, size: 1},
+		{server: server2, size: 1},
+		{server: server1, size: 2},
+		{server: server1, size: 3},
+	}
+	if !reflect.DeepEqual(config.tasks, want) {
+		t.Errorf("unexpected result:\n- want: %v\n-  got: %v", want, config.tasks)
+	}
+	if config.streams != 2 || config.maxStreams != 2 {
+		t.Errorf("unexpected streams: %d, max: %d", config.streams, config.maxStreams)
+	}
+}
@@ -0,0 +1,113 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Durations of HTTP request phases. Phases not performed by the request, e.g. DNS lookup or connection setup
+// when connection reused, are zero.
+type RequestTiming struct {
+	DNS     time.Duration // DNS lookup
+	Connect time.Duration // TCP connection setup
+	TLS     time.Duration // TLS handshake
+	TTFB    time.Duration // Time from the request written to the first response byte received
+	Reused  bool          // Whether the request reused an established connection
+}
+
+// Statistics of a single request phase.
+type PhaseStat struct {
+	Count int // Number of requests performed the phase
+	Mean  time.Duration
+	Max   time.Duration
+}
+
+func (stat *PhaseStat) add(duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	stat.Count++
+	stat.Mean += (duration - stat.Mean) / time.Duration(stat.Count)
+	if duration > stat.Max {
+		stat.Max = duration
+	}
+}
+
+// Statistics of HTTP request phases aggregated over multiple requests.
+type PhaseStats struct {
+	Requests int // Number of traced requests
+	Reused   int // Number of requests reused established connections
+	DNS      PhaseStat
+	Connect  PhaseStat
+	TLS      PhaseStat
+	TTFB     PhaseStat
+}
+
+func (stats *PhaseStats) add(timing RequestTiming) {
+	stats.Requests++
+	if timing.Reused {
+		stats.Reused++
+	}
+	stats.DNS.add(timing.DNS)
+	stats.Connect.add(timing.Connect)
+	stats.TLS.add(timing.TLS)
+	stats.TTFB.add(timing.TTFB)
+}
+
+// Traces the phases of HTTP request performed with the returned context.
+// The returned function reports the phase durations traced so far.
+func traceRequest(ctx context.Context) (context.Context, func() RequestTiming) {
+	var mutex sync.Mutex
+	var timing RequestTiming
+	var dnsStart, connectStart, tlsStart, wrote time.Time
+
+	// Hooks may be called concurrently, e.g. when dialing multiple addresses.
+	record := func(f func()) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		f()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			record(func() { dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { timing.DNS = time.Since(dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			record(func() { connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			record(func() { timing.Connect = time.Since(connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			record(func() { tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { timing.TLS = time.Since(tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func() { timing.Reused = info.Reused })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			record(func() { wrote = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			record(func() {
+				if !wrote.IsZero() {
+					timing.TTFB = time.Since(wrote)
+				}
+			})
+		},
+	}
+
+	return httptrace.WithClientTrace(ctx, trace), func() RequestTiming {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return timing
+	}
+}
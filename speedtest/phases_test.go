@@ -0,0 +1,92 @@
+package speedtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhaseStats_add(t *testing.T) {
+	var stats PhaseStats
+	stats.add(RequestTiming{DNS: 2 * time.Millisecond, Connect: 10 * time.Millisecond, TTFB: 20 * time.Millisecond})
+	stats.add(RequestTiming{TTFB: 30 * time.Millisecond, Reused: true})
+	stats.add(RequestTiming{Connect: 20 * time.Millisecond, TTFB: 40 * time.Millisecond})
+
+	want := PhaseStats{
+		Requests: 3,
+		Reused:   1,
+		DNS:      PhaseStat{Count: 1, Mean: 2 * time.Millisecond, Max: 2 * time.Millisecond},
+		Connect:  PhaseStat{Count: 2, Mean: 15 * time.Millisecond, Max: 20 * time.Millisecond},
+		TTFB:     PhaseStat{Count: 3, Mean: 30 * time.Millisecond, Max: 40 * time.Millisecond},
+	}
+	if stats != want {
+		t.Errorf("unexpected result:\n- want: %+v\n-  got: %+v", want, stats)
+	}
+}
+
+func TestTraceRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("test=test"))
+	}))
+	defer ts.Close()
+	url := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1) // Resolve the host name
+
+	c, err := NewClient(&Opts{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+
+	var stats PhaseStats
+	for _, tc := range []struct {
+		name   string
+		reused bool
+	}{
+		{name: "new connection", reused: false},
+		{name: "reused connection", reused: true},
+	} {
+		ctx, timing := traceRequest(context.Background())
+		resp, err := c.GetContext(ctx, url)
+		if err != nil {
+			t.Fatalf("%s: unexpected request error: %v", tc.name, err)
+		}
+		if _, err := resp.ReadContent(); err != nil {
+			t.Fatalf("%s: unexpected read error: %v", tc.name, err)
+		}
+		got := timing()
+		stats.add(got)
+
+		if got.Reused != tc.reused {
+			t.Errorf("%s: unexpected reuse:\n- want: %v\n-  got: %v", tc.name, tc.reused, got.Reused)
+		}
+		if connected := got.DNS > 0 && got.Connect > 0; connected == tc.reused {
+			t.Errorf("%s: unexpected DNS and connect timings: %+v", tc.name, got)
+		}
+		if got.TTFB < 10*time.Millisecond {
+			t.Errorf("%s: unexpected first byte timing: %v", tc.name, got.TTFB)
+		}
+		if got.TLS != 0 {
+			t.Errorf("%s: unexpected TLS timing: %v", tc.name, got.TLS)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		got  int
+		want int
+	}{
+		{name: "requests", got: stats.Requests, want: 2},
+		{name: "reused", got: stats.Reused, want: 1},
+		{name: "DNS count", got: stats.DNS.Count, want: 1},
+		{name: "connect count", got: stats.Connect.Count, want: 1},
+		{name: "TTFB count", got: stats.TTFB.Count, want: 2},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("unexpected %s:\n- want: %v\n-  got: %v",
+				tc.name, tc.want, tc.got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package speedtest
+
+import (
+	"sort"
+	"time"
+)
+
+// Throughput test profile.
+type TestProfile struct {
+	Name            string
+	Duration        time.Duration // Maximum duration of each of download and upload tests
+	Streams         int           // Number of simultaneous streams, or initial one when adaptive
+	Adaptive        bool          // Whether to add streams while throughput keeps rising
+	MaxStreams      int           // Maximum number of streams when adaptive
+	DownloadSizes   []int         // Dimensions of downloaded images
+	DownloadRepeats int           // Number of downloads of each image
+	UploadSizes     []int         // Sizes of uploaded payloads in bytes
+	UploadRepeats   int           // Number of uploads of each payload
+	BufferSize      int           // Download buffer size
+}
+
+// Short test suitable for metered links.
+var QuickProfile = &TestProfile{
+	Name:            "quick",
+	Duration:        3 * time.Second,
+	Streams:         4,
+	MaxStreams:      DefaultMaxStreams,
+	DownloadSizes:   []int{350, 500, 750, 1000, 1500},
+	DownloadRepeats: 4,
+	UploadSizes:     []int{250000},
+	UploadRepeats:   20,
+	BufferSize:      4096,
+}
+
+// Default test, compatible with the original speedtest-cli.
+var StandardProfile = &TestProfile{
+	Name:            "standard",
+	Duration:        10 * time.Second,
+	Streams:         6,
+	MaxStreams:      DefaultMaxStreams,
+	DownloadSizes:   []int{350, 500, 750, 1000, 1500, 2000, 2500, 3000, 3500, 4000},
+	DownloadRepeats: 5,
+	UploadSizes:     []int{250000, 500000},
+	UploadRepeats:   125,
+	BufferSize:      4096,
+}
+
+// Long test for fast links.
+var ThoroughProfile = &TestProfile{
+	Name:            "thorough",
+	Duration:        60 * time.Second,
+	Streams:         6,
+	Adaptive:        true,
+	MaxStreams:      64,
+	DownloadSizes:   []int{1000, 1500, 2000, 2500, 3000, 3500, 4000},
+	DownloadRepeats: 20,
+	UploadSizes:     []int{250000, 500000},
+	UploadRepeats:   500,
+	BufferSize:      65536,
+}
+
+// Test profiles by name.
+var Profiles = map[string]*TestProfile{
+	QuickProfile.Name:    QuickProfile,
+	StandardProfile.Name: StandardProfile,
+	ThoroughProfile.Name: ThoroughProfile,
+}
+
+// Returns sorted names of test profiles.
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Builds throughput test configuration.
+func (profile *TestProfile) transferConfig(phase Phase, tasks []transferTask) *transferConfig {
+	config := &transferConfig{
+		phase:      phase,
+		tasks:      tasks,
+		duration:   profile.Duration,
+		streams:    profile.Streams,
+		maxStreams: profile.Streams,
+		bufferSize: profile.BufferSize,
+	}
+	if config.duration <= 0 {
+		config.duration = StandardProfile.Duration
+	}
+	if config.streams <= 0 {
+		config.streams = StandardProfile.Streams
+		config.maxStreams = config.streams
+	}
+	if config.bufferSize <= 0 {
+		config.bufferSize = StandardProfile.BufferSize
+	}
+	if profile.Adaptive {
+		config.maxStreams = profile.MaxStreams
+		if config.maxStreams <= 0 {
+			config.maxStreams = DefaultMaxStreams
+		}
+		if config.streams > config.maxStreams {
+			config.streams = config.maxStreams
+		}
+	}
+	return config
+}
+
+// Returns the test profile specified in client options, or the standard one.
+func (client *client) profile() *TestProfile {
+	if client.opts.Profile != nil {
+		return client.opts.Profile
+	}
+	return StandardProfile
+}
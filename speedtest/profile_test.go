@@ -0,0 +1,178 @@
+package speedtest
+
+import (
+	"flag"
+	"testing"
+	"time"
+)
+
+func TestTransferConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		profile        *TestProfile
+		wantStreams    int
+		wantMaxStreams int
+		wantDuration   time.Duration
+		wantBufferSize int
+	}{
+		{
+			name:           "quick",
+			profile:        QuickProfile,
+			wantStreams:    4,
+			wantMaxStreams: 4,
+			wantDuration:   3 * time.Second,
+			wantBufferSize: 4096,
+		},
+		{
+			name:           "standard",
+			profile:        StandardProfile,
+			wantStreams:    6,
+			wantMaxStreams: 6,
+			wantDuration:   10 * time.Second,
+			wantBufferSize: 4096,
+		},
+		{
+			name:           "thorough",
+			profile:        ThoroughProfile,
+			wantStreams:    6,
+			wantMaxStreams: 64,
+			wantDuration:   time.Minute,
+			wantBufferSize: 65536,
+		},
+		{
+			name:           "defaults",
+			profile:        &TestProfile{},
+			wantStreams:    6,
+			wantMaxStreams: 6,
+			wantDuration:   10 * time.Second,
+			wantBufferSize: 4096,
+		},
+		{
+			name:           "adaptive",
+			profile:        &TestProfile{Duration: time.Minute, Streams: 4, Adaptive: true, MaxStreams: 16, BufferSize: 1024},
+			wantStreams:    4,
+			wantMaxStreams: 16,
+			wantDuration:   time.Minute,
+			wantBufferSize: 1024,
+		},
+		{
+			name:           "adaptive with default maximum",
+			profile:        &TestProfile{Duration: time.Minute, Streams: 4, Adaptive: true, BufferSize: 1024},
+			wantStreams:    4,
+			wantMaxStreams: DefaultMaxStreams,
+			wantDuration:   time.Minute,
+			wantBufferSize: 1024,
+		},
+		{
+			name:           "adaptive with initial streams exceeding maximum",
+			profile:        &TestProfile{Duration: time.Minute, Streams: 8, Adaptive: true, MaxStreams: 2, BufferSize: 1024},
+			wantStreams:    2,
+			wantMaxStreams: 2,
+			wantDuration:   time.Minute,
+			wantBufferSize: 1024,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			config := tc.profile.transferConfig(PhaseDownload, nil)
+			for _, field := range []struct {
+				name string
+				got  interface{}
+				want interface{}
+			}{
+				{name: "streams", got: config.streams, want: tc.wantStreams},
+				{name: "maxStreams", got: config.maxStreams, want: tc.wantMaxStreams},
+				{name: "duration", got: config.duration, want: tc.wantDuration},
+				{name: "bufferSize", got: config.bufferSize, want: tc.wantBufferSize},
+			} {
+				if field.got != field.want {
+					t.Errorf("unexpected %s:\n- want: %v\n-  got: %v",
+						field.name, field.want, field.got)
+				}
+			}
+		})
+	}
+}
+
+func TestOverride(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		wantStreams    int
+		wantMaxStreams int
+		wantDuration   time.Duration
+		wantBufferSize int
+	}{
+		{
+			name:           "no overrides",
+			args:           []string{},
+			wantStreams:    4,
+			wantMaxStreams: 4,
+			wantDuration:   3 * time.Second,
+			wantBufferSize: 4096,
+		},
+		{
+			name:           "streams and duration",
+			args:           []string{"-streams", "8", "-duration", "5s"},
+			wantStreams:    8,
+			wantMaxStreams: 8,
+			wantDuration:   5 * time.Second,
+			wantBufferSize: 4096,
+		},
+		{
+			name:           "adaptive",
+			args:           []string{"-adaptive", "-max-streams", "16", "-buffer-size", "8192"},
+			wantStreams:    4,
+			wantMaxStreams: 16,
+			wantDuration:   3 * time.Second,
+			wantBufferSize: 8192,
+		},
+		{
+			name:           "explicit zero",
+			args:           []string{"-streams", "0", "-duration", "0"},
+			wantStreams:    6,
+			wantMaxStreams: 6,
+			wantDuration:   10 * time.Second,
+			wantBufferSize: 4096,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			overrides := new(TestProfile)
+			flags := flag.NewFlagSet(tc.name, flag.ContinueOnError)
+			flags.DurationVar(&overrides.Duration, "duration", 0, "")
+			flags.IntVar(&overrides.Streams, "streams", 0, "")
+			flags.BoolVar(&overrides.Adaptive, "adaptive", false, "")
+			flags.IntVar(&overrides.MaxStreams, "max-streams", 0, "")
+			flags.IntVar(&overrides.BufferSize, "buffer-size", 0, "")
+			if err := flags.Parse(tc.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			profile := QuickProfile.override(overrides, flags)
+			if profile.Name != QuickProfile.Name {
+				t.Errorf("unexpected name: %s", profile.Name)
+			}
+			config := profile.transferConfig(PhaseDownload, nil)
+			for _, field := range []struct {
+				name string
+				got  interface{}
+				want interface{}
+			}{
+				{name: "streams", got: config.streams, want: tc.wantStreams},
+				{name: "maxStreams", got: config.maxStreams, want: tc.wantMaxStreams},
+				{name: "duration", got: config.duration, want: tc.wantDuration},
+				{name: "bufferSize", got: config.bufferSize, want: tc.wantBufferSize},
+			} {
+				if field.got != field.want {
+					t.Errorf("unexpected %s:\n- want: %v\n-  got: %v",
+						field.name, field.want, field.got)
+				}
+			}
+		})
+	}
+}
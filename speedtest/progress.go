@@ -0,0 +1,122 @@
+package speedtest
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Test phase.
+type Phase int
+
+const (
+	PhaseDownload Phase = iota
+	PhaseUpload
+)
+
+func (phase Phase) String() string {
+	switch phase {
+	case PhaseDownload:
+		return "download"
+	case PhaseUpload:
+		return "upload"
+	}
+	return "unknown"
+}
+
+// Progress of the running throughput test.
+type Progress struct {
+	Phase    Phase
+	Bytes    int64         // Number of bytes transferred so far
+	Requests int           // Number of requests started so far
+	Elapsed  time.Duration // Time elapsed since the test start
+	Rate     float64       // Instantaneous transfer rate in bytes per second
+	Done     bool          // Whether the test is complete
+}
+
+// Progress observer. Invoked at sample interval while the test is running, and once the test is complete.
+// Invocations are never concurrent.
+type ProgressFunc func(progress Progress)
+
+// State of the running throughput test shared between transfer requests.
+type transferRun struct {
+	config   *transferConfig
+	start    time.Time
+	bytes    int64 // Updated atomically
+	requests int64 // Updated atomically
+}
+
+// Whether the test duration elapsed.
+func (run *transferRun) expired() bool {
+	return time.Since(run.start) > run.config.duration
+}
+
+func (run *transferRun) addBytes(n int64) {
+	atomic.AddInt64(&run.bytes, n)
+}
+
+func (run *transferRun) startRequest() {
+	atomic.AddInt64(&run.requests, 1)
+}
+
+func (run *transferRun) progress(last *Progress) Progress {
+	progress := Progress{
+		Phase:    run.config.phase,
+		Bytes:    atomic.LoadInt64(&run.bytes),
+		Requests: int(atomic.LoadInt64(&run.requests)),
+		Elapsed:  time.Since(run.start),
+	}
+	if last != nil {
+		if elapsed := progress.Elapsed - last.Elapsed; elapsed > 0 {
+			progress.Rate = float64(progress.Bytes - last.Bytes) / elapsed.Seconds()
+		}
+	}
+	return progress
+}
+
+// Samples the number of transferred bytes and reports the progress of the test
+// at the given interval until the returned function is called.
+// The returned function reports the final progress and returns the samples taken.
+func (run *transferRun) monitor(interval time.Duration, report ProgressFunc) (stop func() []Sample) {
+	if interval <= 0 {
+		interval = DefaultSampleInterval
+	}
+	if report == nil {
+		report = func(Progress) {}
+	}
+
+	last := run.progress(nil)
+	report(last)
+
+	samples := make([]Sample, 0, int(run.config.duration / interval) + 1)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				progress := run.progress(&last)
+				samples = append(samples, Sample{Elapsed: progress.Elapsed, Bytes: progress.Bytes})
+				report(progress)
+				last = progress
+			}
+		}
+	}()
+
+	return func() []Sample {
+		close(done)
+		wg.Wait()
+		progress := run.progress(&last)
+		progress.Done = true
+		samples = append(samples, Sample{Elapsed: progress.Elapsed, Bytes: progress.Bytes})
+		report(progress)
+		return samples
+	}
+}
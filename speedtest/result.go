@@ -0,0 +1,334 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Result of a complete speed test run.
+type Result struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Version   string          `json:"version"`
+	Client    *ClientResult   `json:"client,omitempty"` // Nil without speedtest.net configuration
+	Server    ServerResult    `json:"server"`
+	Download  *TransferResult `json:"download,omitempty"`
+	Upload    *TransferResult `json:"upload,omitempty"`
+	Share     string          `json:"share,omitempty"`
+}
+
+// Client the test is performed from.
+type ClientResult struct {
+	IP        string  `json:"ip"`
+	ISP       string  `json:"isp"`
+	Latitude  float32 `json:"lat"`
+	Longitude float32 `json:"lon"`
+}
+
+// Server the test is performed against.
+type ServerResult struct {
+	ID        ServerID      `json:"id"`
+	Sponsor   string        `json:"sponsor"`
+	Name      string        `json:"name"`
+	Country   string        `json:"country"`
+	Host      string        `json:"host"`
+	URL       string        `json:"url"`
+	Distance  *float64      `json:"distance_km,omitempty"` // Unknown without client location
+	LatencyMs float64       `json:"latency_ms"`
+	Latency   LatencyResult `json:"latency"`
+	Phases    *PhasesResult `json:"latency_phases,omitempty"`
+
+	// Latency statistics by measurement mode.
+	Modes map[string]LatencyResult `json:"latency_modes,omitempty"`
+}
+
+// Latency statistics.
+type LatencyResult struct {
+	MinMs    float64 `json:"min_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	MeanMs   float64 `json:"mean_ms"`
+	MedianMs float64 `json:"median_ms"`
+	StdDevMs float64 `json:"stddev_ms"`
+	JitterMs float64 `json:"jitter_ms"`
+	Samples  int     `json:"samples"`
+	Failed   int     `json:"failed"`
+}
+
+// HTTP request phase statistics.
+type PhasesResult struct {
+	Requests int         `json:"requests"`
+	Reused   int         `json:"reused_connections"`
+	DNS      PhaseResult `json:"dns"`
+	Connect  PhaseResult `json:"connect"`
+	TLS      PhaseResult `json:"tls"`
+	TTFB     PhaseResult `json:"ttfb"`
+}
+
+// Statistics of a single HTTP request phase.
+type PhaseResult struct {
+	Count  int     `json:"count"`
+	MeanMs float64 `json:"mean_ms"`
+	MaxMs  float64 `json:"max_ms"`
+}
+
+// Outcome of download or upload test.
+type TransferResult struct {
+	BitsPerSecond  float64  `json:"bits_per_second"`
+	BytesPerSecond float64  `json:"bytes_per_second"`
+	Bytes          int64    `json:"bytes"`
+	DurationMs     float64  `json:"duration_ms"`
+	Started        int      `json:"requests_started"`
+	Completed      int      `json:"requests_completed"`
+	Failed         int      `json:"requests_failed"`
+	Streams        int      `json:"streams"`
+	Errors         []string `json:"errors,omitempty"`
+	Interrupted    bool     `json:"interrupted,omitempty"`
+
+	WireBitsPerSecond float64 `json:"wire_bits_per_second"`
+	WireBytes         int64   `json:"wire_bytes"`
+	OverheadPercent   float64 `json:"overhead_percent"`
+
+	LoadedLatency    *LatencyResult `json:"loaded_latency,omitempty"`
+	BufferbloatGrade string         `json:"bufferbloat_grade,omitempty"`
+	Phases           *PhasesResult  `json:"phases,omitempty"`
+
+	// Breakdown by server when the test is performed against multiple servers simultaneously.
+	Servers []ServerShareResult `json:"servers,omitempty"`
+
+	Throughput ThroughputResult `json:"throughput"`
+	Samples    []SampleResult   `json:"samples,omitempty"`
+}
+
+// Result of tests performed against multiple servers.
+type MatrixResult struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Version   string          `json:"version"`
+	Client    *ClientResult   `json:"client,omitempty"` // Nil without speedtest.net configuration
+	Servers   []*Result       `json:"servers"`
+	Aggregate AggregateResult `json:"aggregate"`
+}
+
+// Aggregate of the results of tests performed against multiple servers.
+type AggregateResult struct {
+	Latency  AggregateStat `json:"latency_ms"`
+	Download AggregateStat `json:"download_bits_per_second"`
+	Upload   AggregateStat `json:"upload_bits_per_second"`
+}
+
+// Aggregate of a single value measured against multiple servers.
+type AggregateStat struct {
+	Count  int     `json:"count"`
+	Best   float64 `json:"best"`
+	Median float64 `json:"median"`
+}
+
+// Creates a result of the test performed from the client with the given config against the given server.
+func NewResult(config *Config, server *Server) *Result {
+	result := &Result{
+		Timestamp: time.Now().UTC(),
+		Version:   Version,
+	}
+	if config != nil {
+		result.Client = &ClientResult{
+			IP:        config.Client.IP,
+			ISP:       config.Client.ISP,
+			Latitude:  config.Client.Latitude,
+			Longitude: config.Client.Longitude,
+		}
+	}
+	if server != nil {
+		result.Server = ServerResult{
+			ID:        server.ID,
+			Sponsor:   server.Sponsor,
+			Name:      server.Name,
+			Country:   server.Country,
+			Host:      server.Host,
+			URL:       server.URL,
+			LatencyMs: milliseconds(server.Latency),
+			Latency:   NewLatencyResult(&server.LatencyStats),
+			Phases:    NewPhasesResult(&server.LatencyPhases),
+		}
+		if server.HasDistance() {
+			distance := server.Distance
+			result.Server.Distance = &distance
+		}
+		for mode, stats := range server.ModeLatencies {
+			if result.Server.Modes == nil {
+				result.Server.Modes = make(map[string]LatencyResult)
+			}
+			result.Server.Modes[mode.String()] = NewLatencyResult(&stats)
+		}
+	}
+	return result
+}
+
+// Part of the transfer related to particular server.
+type ServerShareResult struct {
+	ID            ServerID `json:"id"`
+	Sponsor       string   `json:"sponsor"`
+	Name          string   `json:"name"`
+	Bytes         int64    `json:"bytes"`
+	BitsPerSecond float64  `json:"bits_per_second"`
+	Share         float64  `json:"share"`
+}
+
+// Throughput statistics. Rates are in bits per second.
+type ThroughputResult struct {
+	Mean      float64 `json:"mean_bits_per_second"`
+	Peak      float64 `json:"peak_bits_per_second"`
+	P10       float64 `json:"p10_bits_per_second"`
+	P50       float64 `json:"p50_bits_per_second"`
+	P90       float64 `json:"p90_bits_per_second"`
+	Stability float64 `json:"stability_cov"`
+	Stable    float64 `json:"stable_bits_per_second"`
+	RampUpMs  float64 `json:"ramp_up_ms"`
+}
+
+// Cumulative number of bytes transferred since the test start.
+type SampleResult struct {
+	ElapsedMs float64 `json:"elapsed_ms"`
+	Bytes     int64   `json:"bytes"`
+}
+
+// Creates a latency result from the given statistics.
+func NewLatencyResult(stats *LatencyStats) LatencyResult {
+	return LatencyResult{
+		MinMs:    milliseconds(stats.Min),
+		MaxMs:    milliseconds(stats.Max),
+		MeanMs:   milliseconds(stats.Mean),
+		MedianMs: milliseconds(stats.Median),
+		StdDevMs: milliseconds(stats.StdDev),
+		JitterMs: milliseconds(stats.Jitter),
+		Samples:  len(stats.Samples),
+		Failed:   stats.Failed,
+	}
+}
+
+// Creates a phases result from the given statistics. Returns nil if no requests traced.
+func NewPhasesResult(stats *PhaseStats) *PhasesResult {
+	if stats.Requests == 0 {
+		return nil
+	}
+	phase := func(stat PhaseStat) PhaseResult {
+		return PhaseResult{Count: stat.Count, MeanMs: milliseconds(stat.Mean), MaxMs: milliseconds(stat.Max)}
+	}
+	return &PhasesResult{
+		Requests: stats.Requests,
+		Reused:   stats.Reused,
+		DNS:      phase(stats.DNS),
+		Connect:  phase(stats.Connect),
+		TLS:      phase(stats.TLS),
+		TTFB:     phase(stats.TTFB),
+	}
+}
+
+// Creates a transfer result from the given measurement.
+// Bufferbloat is graded against the given idle latency statistics when latency under load is measured.
+func NewTransferResult(m *Measurement, idle *LatencyStats) *TransferResult {
+	result := &TransferResult{
+		Bytes:       m.Bytes,
+		DurationMs:  milliseconds(m.Duration),
+		Started:     m.Started,
+		Completed:   m.Completed,
+		Failed:      m.Failed,
+		Streams:     m.Streams,
+		Interrupted: m.Err != nil,
+		Phases:      NewPhasesResult(&m.Phases),
+	}
+	for _, err := range m.Errors {
+		result.Errors = append(result.Errors, err.Error())
+	}
+	result.Throughput = ThroughputResult{
+		Mean:      m.Throughput.Mean * 8,
+		Peak:      m.Throughput.Peak * 8,
+		P10:       m.Throughput.P10 * 8,
+		P50:       m.Throughput.P50 * 8,
+		P90:       m.Throughput.P90 * 8,
+		Stability: m.Throughput.Stability,
+		Stable:    m.Throughput.Stable * 8,
+		RampUpMs:  milliseconds(m.Throughput.RampUp),
+	}
+	for _, sample := range m.Samples {
+		result.Samples = append(result.Samples, SampleResult{
+			ElapsedMs: milliseconds(sample.Elapsed),
+			Bytes:     sample.Bytes,
+		})
+	}
+	if m.LoadedLatency != nil {
+		loaded := NewLatencyResult(m.LoadedLatency)
+		result.LoadedLatency = &loaded
+		result.BufferbloatGrade = BufferbloatGrade(idle, m.LoadedLatency)
+	}
+	if len(m.Servers) > 1 {
+		for _, server := range m.Servers {
+			share := ServerShareResult{Bytes: server.Bytes, Share: server.Share(m)}
+			if server.Server != nil {
+				share.ID = server.Server.ID
+				share.Sponsor = server.Server.Sponsor
+				share.Name = server.Server.Name
+			}
+			if m.Duration > 0 {
+				share.BitsPerSecond = float64(server.Bytes) * 8 / m.Duration.Seconds()
+			}
+			result.Servers = append(result.Servers, share)
+		}
+	}
+	result.WireBytes = m.WireBytes
+	result.OverheadPercent = m.Overhead()
+	if m.Duration > 0 {
+		result.BytesPerSecond = float64(m.Bytes) / m.Duration.Seconds()
+		result.BitsPerSecond = result.BytesPerSecond * 8
+		result.WireBitsPerSecond = float64(m.WireBytes) * 8 / m.Duration.Seconds()
+	}
+	return result
+}
+
+// Creates a result of the tests performed from the client with the given config against multiple servers.
+// Download and upload of each server are graded for bufferbloat against its idle warm latency.
+func NewMatrixResult(config *Config, matrix *Matrix) *MatrixResult {
+	result := &MatrixResult{
+		Timestamp: time.Now().UTC(),
+		Version:   Version,
+		Servers:   make([]*Result, 0, len(matrix.Entries)),
+		Aggregate: AggregateResult{
+			Latency:  newAggregateStat(matrix.Latency, 1/float64(time.Millisecond)),
+			Download: newAggregateStat(matrix.Download, 8),
+			Upload:   newAggregateStat(matrix.Upload, 8),
+		},
+	}
+	for _, entry := range matrix.Entries {
+		server := NewResult(config, entry.Server)
+		if entry.Download != nil {
+			server.Download = NewTransferResult(entry.Download, entry.Server.IdleLatency())
+		}
+		if entry.Upload != nil {
+			server.Upload = NewTransferResult(entry.Upload, entry.Server.IdleLatency())
+		}
+		result.Client = server.Client
+		result.Servers = append(result.Servers, server)
+	}
+	return result
+}
+
+// Converts the statistics to the reported units by multiplying them by the given scale.
+func newAggregateStat(stats MatrixStats, scale float64) AggregateStat {
+	return AggregateStat{Count: stats.Count, Best: stats.Best * scale, Median: stats.Median * scale}
+}
+
+// Writes the matrix result as indented JSON document.
+func (result *MatrixResult) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// Writes the result as indented JSON document.
+func (result *Result) WriteJSON(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,210 @@
+package speedtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResultWriteJSON(t *testing.T) {
+	server := &Server{
+		ID:       1234,
+		Sponsor:  "Sponsor",
+		Name:     "City",
+		Country:  "Country",
+		Host:     "speedtest.example.com:8080",
+		URL:      "http://speedtest.example.com/speedtest/upload.php",
+		Distance: 12.5,
+		Latency:  20 * time.Millisecond,
+	}
+	result := NewResult(&Config{Client: ClientConfig{IP: "10.0.0.1", ISP: "ISP"}}, server)
+	result.Download = NewTransferResult(&Measurement{Bytes: 2500000, Duration: 2 * time.Second}, nil)
+
+	var out bytes.Buffer
+	if err := result.WriteJSON(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	object := func(value interface{}) map[string]interface{} {
+		m, _ := value.(map[string]interface{})
+		return m
+	}
+	clientDoc := object(doc["client"])
+	serverDoc := object(doc["server"])
+	downloadDoc := object(doc["download"])
+
+	for _, tc := range []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{name: "version", got: doc["version"], want: Version},
+		{name: "client.ip", got: clientDoc["ip"], want: "10.0.0.1"},
+		{name: "client.isp", got: clientDoc["isp"], want: "ISP"},
+		{name: "server.id", got: serverDoc["id"], want: 1234.0},
+		{name: "server.host", got: serverDoc["host"], want: "speedtest.example.com:8080"},
+		{name: "server.distance_km", got: serverDoc["distance_km"], want: 12.5},
+		{name: "server.latency_ms", got: serverDoc["latency_ms"], want: 20.0},
+		{name: "download.bytes", got: downloadDoc["bytes"], want: 2500000.0},
+		{name: "download.duration_ms", got: downloadDoc["duration_ms"], want: 2000.0},
+		{name: "download.bytes_per_second", got: downloadDoc["bytes_per_second"], want: 1250000.0},
+		{name: "download.bits_per_second", got: downloadDoc["bits_per_second"], want: 10000000.0},
+	} {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("unexpected %s:\n- want: %v\n-  got: %v",
+				tc.name, tc.want, tc.got)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		doc     map[string]interface{}
+		key     string
+		present bool
+	}{
+		{name: "timestamp", doc: doc, key: "timestamp", present: true},
+		{name: "download", doc: doc, key: "download", present: true},
+		{name: "upload", doc: doc, key: "upload", present: false},
+		{name: "share", doc: doc, key: "share", present: false},
+		{name: "server.latency", doc: serverDoc, key: "latency", present: true},
+		{name: "server.latency_phases", doc: serverDoc, key: "latency_phases", present: false},
+		{name: "server.latency_modes", doc: serverDoc, key: "latency_modes", present: false},
+		{name: "download.throughput", doc: downloadDoc, key: "throughput", present: true},
+		{name: "download.errors", doc: downloadDoc, key: "errors", present: false},
+		{name: "download.interrupted", doc: downloadDoc, key: "interrupted", present: false},
+		{name: "download.loaded_latency", doc: downloadDoc, key: "loaded_latency", present: false},
+		{name: "download.servers", doc: downloadDoc, key: "servers", present: false},
+		{name: "download.samples", doc: downloadDoc, key: "samples", present: false},
+	} {
+		if _, present := tc.doc[tc.key]; present != tc.present {
+			t.Errorf("unexpected presence of %s:\n- want: %v\n-  got: %v",
+				tc.name, tc.present, present)
+		}
+	}
+
+	var decoded Result
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("unexpected decoding error: %v", err)
+	}
+	if !decoded.Timestamp.Equal(result.Timestamp) {
+		t.Errorf("unexpected timestamp:\n- want: %v\n-  got: %v", result.Timestamp, decoded.Timestamp)
+	}
+	decoded.Timestamp = result.Timestamp
+	if !reflect.DeepEqual(&decoded, result) {
+		t.Errorf("unexpected result:\n- want: %+v\n-  got: %+v", result, &decoded)
+	}
+}
+
+func TestNewTransferResultServers(t *testing.T) {
+	server1 := &Server{ID: 1, Sponsor: "Sponsor 1", Name: "City 1"}
+	server2 := &Server{ID: 2, Sponsor: "Sponsor 2", Name: "City 2"}
+
+	tests := []struct {
+		name        string
+		measurement *Measurement
+		want        []ServerShareResult
+	}{
+		{
+			name: "single server",
+			measurement: &Measurement{
+				Bytes:    1000000,
+				Duration: 2 * time.Second,
+				Servers:  []*ServerMeasurement{{Server: server1, Bytes: 1000000}},
+			},
+		},
+		{
+			name: "multiple servers",
+			measurement: &Measurement{
+				Bytes:    1000000,
+				Duration: 2 * time.Second,
+				Servers: []*ServerMeasurement{
+					{Server: server1, Bytes: 750000},
+					{Server: server2, Bytes: 250000},
+				},
+			},
+			want: []ServerShareResult{
+				{ID: 1, Sponsor: "Sponsor 1", Name: "City 1", Bytes: 750000, BitsPerSecond: 3000000, Share: 0.75},
+				{ID: 2, Sponsor: "Sponsor 2", Name: "City 2", Bytes: 250000, BitsPerSecond: 1000000, Share: 0.25},
+			},
+		},
+		{
+			name: "no duration",
+			measurement: &Measurement{
+				Servers: []*ServerMeasurement{{Server: server1}, {Server: server2}},
+			},
+			want: []ServerShareResult{
+				{ID: 1, Sponsor: "Sponsor 1", Name: "City 1"},
+				{ID: 2, Sponsor: "Sponsor 2", Name: "City 2"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewTransferResult(tc.measurement, nil).Servers
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %+v\n-  got: %+v",
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewResultDistance(t *testing.T) {
+	known := 12.5
+	tests := []struct {
+		name     string
+		distance float64
+		want     *float64
+		wantText string
+	}{
+		{name: "known", distance: known, want: &known, wantText: "12.50 km"},
+		{name: "unknown", distance: UnknownDistance, want: nil, wantText: "unknown distance"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			server := &Server{ID: 1234, Distance: tc.distance}
+			if got := NewResult(nil, server).Server.Distance; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v", tc.want, got)
+			}
+			if got := server.DistanceString(); got != tc.wantText {
+				t.Errorf("unexpected text:\n- want: %v\n-  got: %v", tc.wantText, got)
+			}
+		})
+	}
+}
+
+func TestResultWriteJSONWithoutConfig(t *testing.T) {
+	result := NewResult(nil, &Server{ID: 1234, Distance: UnknownDistance})
+
+	var out bytes.Buffer
+	if err := result.WriteJSON(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+	serverDoc, _ := doc["server"].(map[string]interface{})
+	for _, tc := range []struct {
+		name string
+		doc  map[string]interface{}
+		key  string
+	}{
+		{name: "client", doc: doc, key: "client"},
+		{name: "server.distance_km", doc: serverDoc, key: "distance_km"},
+	} {
+		if _, present := tc.doc[tc.key]; present {
+			t.Errorf("unexpected %s: %v", tc.name, tc.doc[tc.key])
+		}
+	}
+}
@@ -1,46 +1,65 @@
 package speedtest
 
 import (
+	"context"
 	"errors"
 	"sort"
 	"fmt"
 	"time"
 	"net/url"
-	"log"
 )
 
 type ServerID uint64
 
 type Server struct {
 	Coordinates
-	URL      string `xml:"url,attr"`
-	Name     string `xml:"name,attr"`
-	Country  string `xml:"country,attr"`
-	CC       string `xml:"cc,attr"`
-	Sponsor  string `xml:"sponsor,attr"`
-	ID       ServerID `xml:"id,attr"`
-	URL2     string `xml:"url2,attr"`
-	Host     string `xml:"host,attr"`
-	client   Client `xml:"-"`
-	Distance float64 `xml:"-"`
-	Latency  time.Duration `xml:"-"`
+	URL           string `xml:"url,attr"`
+	Name          string `xml:"name,attr"`
+	Country       string `xml:"country,attr"`
+	CC            string `xml:"cc,attr"`
+	Sponsor       string `xml:"sponsor,attr"`
+	ID            ServerID `xml:"id,attr"`
+	URL2          string `xml:"url2,attr"`
+	Host          string `xml:"host,attr"`
+	client        Client `xml:"-"`
+	Distance      float64 `xml:"-" json:"-"`
+	Latency       time.Duration `xml:"-" json:"-"`
+	LatencyStats  LatencyStats `xml:"-" json:"-"`
+	LatencyPhases PhaseStats `xml:"-" json:"-"` // HTTP request phases of latency probes
+	ModeLatencies map[LatencyMode]LatencyStats `xml:"-" json:"-"` // Latency statistics by measurement mode
 }
 
+// Distance to the server when the client location is unknown.
+const UnknownDistance = -1
+
 func (s *Server) String() string {
-	return fmt.Sprintf("%8d: %s (%s, %s) [%.2f km] %s", s.ID, s.Sponsor, s.Name, s.Country, s.Distance, s.URL)
+	return fmt.Sprintf("%8d: %s (%s, %s) [%s] %s", s.ID, s.Sponsor, s.Name, s.Country, s.DistanceString(), s.URL)
+}
+
+// Whether the distance to the server is known.
+func (s *Server) HasDistance() bool {
+	return s.Distance >= 0
 }
 
-func (s *Server) RelativeURL(local string) string {
+// Formats the distance to the server for display.
+func (s *Server) DistanceString() string {
+	if !s.HasDistance() {
+		return "unknown distance"
+	}
+	return fmt.Sprintf("%.2f km", s.Distance)
+}
+
+// Resolves the given URL relative to the server URL.
+func (s *Server) RelativeURL(local string) (string, error) {
 	u, err := url.Parse(s.URL)
 	if err != nil {
-		log.Fatalf("[%s] Failed to parse server URL: %v\n", s.URL, err)
-		return ""
+		return "", &ServerURLError{URL: s.URL, Err: err}
 	}
 	localURL, err := url.Parse(local)
 	if err != nil {
-		log.Fatalf("Failed to parse local URL `%s`: %v\n", local, err);
+		return "", err
 	}
-	return u.ResolveReference(localURL).String()
+	return u.ResolveReference(localURL).String(), nil
 }
 
 type Servers struct {
@@ -117,82 +136,98 @@ func (servers *Servers) append(other *Servers) *Servers {
 	return servers
 }
 
+// Sorts servers by distance to the client with the given config, and then by ID.
+// Distances are unknown without config, so servers are sorted by ID only.
 func (servers *Servers) sort(client Client, config *Config) {
 	for _, server := range servers.List {
 		server.client = client;
-		server.Distance = server.DistanceTo(config.Client.Coordinates)
+		if config != nil {
+			server.Distance = server.DistanceTo(config.Client.Coordinates)
+		} else {
+			server.Distance = UnknownDistance
+		}
 	}
-	sort.Sort(servers)
+	sort.Stable(servers)
 }
 
+// Removes servers with the same ID, keeping the first one.
 func (servers *Servers) deduplicate() {
 	dedup := make([]*Server, 0, len(servers.List));
-	var prevId ServerID = 0;
+	seen := make(map[ServerID]bool, len(servers.List));
 	for _, server := range servers.List {
-		if prevId != server.ID {
-			prevId = server.ID
+		if !seen[server.ID] {
+			seen[server.ID] = true
 			dedup = append(dedup, server);
 		}
 	}
 	servers.List = dedup
 }
 
-var serverURLs = [...]string{
-	"://www.speedtest.net/speedtest-servers-static.php",
-	"://c.speedtest.net/speedtest-servers-static.php",
-	"://www.speedtest.net/speedtest-servers.php",
-	"://c.speedtest.net/speedtest-servers.php",
-}
-
 var NoServersError error = errors.New("No servers available")
 
 func (client *client) AllServers() (*Servers, error) {
-	serversChan := make(chan ServersRef)
-	client.LoadAllServers(serversChan)
-	serversRef := <-serversChan
-	return serversRef.Servers, serversRef.Error
+	return client.AllServersContext(context.Background())
+}
+
+func (client *client) AllServersContext(ctx context.Context) (*Servers, error) {
+	serversChan := make(chan ServersRef, 1)
+	client.loadAllServersContext(ctx, serversChan)
+	return waitServers(ctx, serversChan)
 }
 
 func (client *client) LoadAllServers(ret chan ServersRef) {
+	client.loadAllServersContext(context.Background(), ret)
+}
+
+// Loads the server list unless the context is done.
+// The list is loaded once and shared by subsequent calls, unless the loading is interrupted.
+func (client *client) loadAllServersContext(ctx context.Context, ret chan ServersRef) {
 	client.mutex.Lock()
 	defer client.mutex.Unlock()
 
 	if client.allServers == nil {
 		client.allServers = make(chan ServersRef)
-		go client.loadServers()
+		go client.loadServers(ctx, client.allServers)
 	}
 
+	allServers := client.allServers
 	go func() {
-		result := <-client.allServers
+		result := <-allServers
 		ret <- result
-		client.allServers <- result// Make it available again
+		allServers <- result// Make it available again
 	}()
 }
 
-func (client *client) loadServers() {
-	configChan := make(chan ConfigRef)
-	client.LoadConfig(configChan);
+func (client *client) loadServers(ctx context.Context, allServers chan ServersRef) {
+	configChan := make(chan ConfigRef, 1)
+	client.loadConfigContext(ctx, configChan);
 
-	client.Log("Retrieving speedtest.net server list...")
-
-	serversChan := make(chan *Servers, len(serverURLs))
-	for _, url := range serverURLs {
-		go client.loadServersFrom(url, serversChan)
+	servers := &Servers{}
+	if !client.opts.NoDefaultServers {
+		servers = client.loadDefaultServers(ctx)
 	}
 
-	var servers *Servers
-
-	for range serverURLs {
-		servers = servers.append(<-serversChan);
+	if len(client.opts.ServerSources) != 0 {
+		servers = servers.append(client.fetchServers(ctx, client.opts.ServerSources))
 	}
 
 	result := ServersRef{}
 
-	if servers.Len() == 0 {
+	if ctx.Err() != nil {
+		result.Error = ctx.Err()
+		client.mutex.Lock()
+		client.allServers = nil // Load again next time
+		client.mutex.Unlock()
+	} else if servers.Len() == 0 {
 		result.Error = NoServersError
 	} else {
 		configRef := <-configChan
-		if configRef.Error != nil {
+		if configRef.Error != nil && len(client.opts.ServerSources) != 0 {
+			client.Log("%v. Distances to servers are unknown", configRef.Error)
+			servers.sort(client, nil)
+			servers.deduplicate()
+			result.Servers = servers
+		} else if configRef.Error != nil {
 			result.Error = configRef.Error
 		} else {
 			servers.sort(client, configRef.Config)
@@ -201,55 +236,116 @@ func (client *client) loadServers() {
 		}
 	}
 
-	client.allServers <- result
+	allServers <- result
 }
 
-func (client *client) loadServersFrom(url string, ret chan *Servers) {
-	resp, err := client.Get(url)
-	if resp != nil {
-		url = resp.Request.URL.String()
+// Loads speedtest.net server list from cache, or retrieves it when the cache is stale.
+// Falls back to stale cache when the list can not be retrieved.
+func (client *client) loadDefaultServers(ctx context.Context) *Servers {
+	servers := &Servers{}
+	found, fresh := client.loadCached("servers.json", servers)
+	if fresh {
+		return servers
 	}
-	if err != nil {
-		client.Log("[%s] Failed to retrieve server list: %v", url, err)
+	client.Log("Retrieving speedtest.net server list...")
+	fetched := client.fetchServers(ctx, DefaultServerSources)
+	if fetched.Len() != 0 {
+		client.storeCached("servers.json", fetched)
+		return fetched
+	}
+	if found {
+		client.Log("%v. Using cached server list", NoServersError)
+		return servers
+	}
+	return fetched
+}
+
+// Loads servers from all of the given sources simultaneously, and merges them.
+func (client *client) fetchServers(ctx context.Context, sources []ServerSource) *Servers {
+	serversChan := make(chan *Servers, len(sources))
+	for _, source := range sources {
+		go client.loadServersFrom(ctx, source, serversChan)
 	}
 
 	servers := &Servers{}
-	if err = resp.ReadXML(servers); err != nil {
-		client.Log("[%s] Failed to read server list: %v", url, err)
+
+	for range sources {
+		servers = servers.append(<-serversChan);
+	}
+
+	return servers
+}
+
+func (client *client) loadServersFrom(ctx context.Context, source ServerSource, ret chan *Servers) {
+	servers, err := source.LoadServers(ctx, client)
+	if err != nil {
+		if ctx.Err() == nil {
+			client.Log("[%v] Failed to retrieve server list: %v", source, err)
+		}
+		servers = &Servers{}
 	}
 	ret <- servers
 }
 
 func (client *client) ClosestServers() (*Servers, error) {
-	serversChan := make(chan ServersRef)
-	client.LoadClosestServers(serversChan)
-	serversRef := <-serversChan
-	return serversRef.Servers, serversRef.Error
+	return client.ClosestServersContext(context.Background())
+}
+
+func (client *client) ClosestServersContext(ctx context.Context) (*Servers, error) {
+	serversChan := make(chan ServersRef, 1)
+	client.loadClosestServersContext(ctx, serversChan)
+	return waitServers(ctx, serversChan)
+}
+
+func waitServers(ctx context.Context, serversChan chan ServersRef) (*Servers, error) {
+	select {
+	case serversRef := <-serversChan:
+		return serversRef.Servers, serversRef.Error
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 func (client *client) LoadClosestServers(ret chan ServersRef) {
+	client.loadClosestServersContext(context.Background(), ret)
+}
+
+// Loads the closest servers unless the context is done.
+// The servers are loaded once and shared by subsequent calls, unless the loading is interrupted.
+func (client *client) loadClosestServersContext(ctx context.Context, ret chan ServersRef) {
 	client.mutex.Lock()
 	defer client.mutex.Unlock()
 
 	if client.closestServers == nil {
 		client.closestServers = make(chan ServersRef)
-		go client.loadClosestServers()
+		go client.loadClosestServers(ctx, client.closestServers)
 	}
 
+	closestServers := client.closestServers
 	go func() {
-		result := <-client.closestServers
+		result := <-closestServers
 		ret <- result
-		client.closestServers <- result// Make it available again
+		closestServers <- result// Make it available again
 	}()
 }
 
-func (client *client) loadClosestServers() {
+func (client *client) loadClosestServers(ctx context.Context, closestServers chan ServersRef) {
 	serversChan := make(chan ServersRef)
-	client.LoadAllServers(serversChan)
+	client.loadAllServersContext(ctx, serversChan)
 	serversRef := <-serversChan
 	if serversRef.Error != nil {
-		client.closestServers <- serversRef
+		if ctx.Err() != nil {
+			client.mutex.Lock()
+			client.closestServers = nil // Load again next time
+			client.mutex.Unlock()
+		}
+		closestServers <- serversRef
 	} else {
-		client.closestServers <- ServersRef{serversRef.Servers.truncate(5), nil}
+		servers := serversRef.Servers.Filter(client.opts.Filter)
+		if servers.Len() == 0 {
+			closestServers <- ServersRef{nil, NoServersError}
+		} else {
+			closestServers <- ServersRef{servers.truncate(5), nil}
+		}
 	}
 }
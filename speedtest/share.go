@@ -0,0 +1,93 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Default speedtest.net result submission API URL.
+const DefaultShareURL = "://www.speedtest.net/api/api.php"
+
+const shareAPIKey = "297aae72"
+const shareReferer = "http://c.speedtest.net/flash/speedtest.swf"
+
+var ShareFailedError error = errors.New("Failed to submit results to speedtest.net")
+
+// Submits the result to speedtest.net result API at the given URL.
+// Returns the URL of the result image. It has the same scheme as the API URL.
+func Share(client Client, apiURL string, result *Result) (string, error) {
+	return ShareContext(context.Background(), client, apiURL, result)
+}
+
+// Submits the result to speedtest.net result API at the given URL unless the context is done.
+// Returns the URL of the result image. It has the same scheme as the API URL.
+func ShareContext(ctx context.Context, client Client, apiURL string, result *Result) (string, error) {
+	ping := int(math.Floor(result.Server.LatencyMs + 0.5))
+	download := kilobits(result.Download)
+	upload := kilobits(result.Upload)
+	serverID := strconv.FormatUint(uint64(result.Server.ID), 10)
+
+	form := url.Values{}
+	form.Set("recommendedserverid", serverID)
+	form.Set("ping", strconv.Itoa(ping))
+	form.Set("screenresolution", "")
+	form.Set("promo", "")
+	form.Set("download", strconv.Itoa(download))
+	form.Set("screendpi", "")
+	form.Set("upload", strconv.Itoa(upload))
+	form.Set("testmethod", "http")
+	form.Set("hash", fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%d-%d-%d-%s", ping, upload, download, shareAPIKey)))))
+	form.Set("touchscreen", "none")
+	form.Set("startmode", "pingselect")
+	form.Set("accuracy", "1")
+	form.Set("bytesreceived", strconv.FormatInt(transferredBytes(result.Download), 10))
+	form.Set("bytessent", strconv.FormatInt(transferredBytes(result.Upload), 10))
+	form.Set("serverid", serverID)
+
+	req, err := client.NewRequestContext(ctx, "POST", apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", shareReferer)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	content, err := resp.ReadContent()
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != 200 {
+		return "", &HTTPStatusError{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode}
+	}
+
+	values, err := url.ParseQuery(string(content))
+	if err != nil {
+		return "", err
+	}
+	resultIDs := values["resultid"]
+	if len(resultIDs) != 1 || len(resultIDs[0]) == 0 {
+		return "", ShareFailedError
+	}
+
+	return fmt.Sprintf("%s://www.speedtest.net/result/%s.png", req.URL.Scheme, resultIDs[0]), nil
+}
+
+func kilobits(transfer *TransferResult) int {
+	return int(math.Floor(bitsPerSecond(transfer)/1000 + 0.5))
+}
+
+func transferredBytes(transfer *TransferResult) int64 {
+	if transfer == nil {
+		return 0
+	}
+	return transfer.Bytes
+}
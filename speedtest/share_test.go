@@ -0,0 +1,151 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShare(t *testing.T) {
+	result := &Result{
+		Server:   ServerResult{ID: 1234, LatencyMs: 19.6},
+		Download: &TransferResult{BitsPerSecond: 10499000, Bytes: 13000000},
+		Upload:   &TransferResult{BitsPerSecond: 2000400, Bytes: 2500000},
+	}
+
+	tests := []struct {
+		name     string
+		secure   bool
+		response string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "result ID returned",
+			response: "resultid=4321&date=1%2F1%2F2018",
+			want:     "http://www.speedtest.net/result/4321.png",
+		},
+		{
+			name:     "secure result URL",
+			secure:   true,
+			response: "resultid=4321&date=1%2F1%2F2018",
+			want:     "https://www.speedtest.net/result/4321.png",
+		},
+		{
+			name:     "no result ID",
+			response: "error=invalid",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var form map[string][]string
+			var referer string
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Errorf("unexpected form error: %v", err)
+				}
+				form = r.PostForm
+				referer = r.Referer()
+				w.Write([]byte(tc.response))
+			})
+			var ts *httptest.Server
+			if tc.secure {
+				ts = httptest.NewTLSServer(handler)
+			} else {
+				ts = httptest.NewServer(handler)
+			}
+			defer ts.Close()
+
+			c, err := NewClient(&Opts{Timeout: 10 * time.Second})
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			c.(*client).Transport = ts.Client().Transport
+			got, err := Share(c, ts.URL, result)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := tc.want; got != want {
+				t.Fatalf("unexpected result:\n- want: %v\n-  got: %v",
+					want, got)
+			}
+			if want := shareReferer; referer != want {
+				t.Errorf("unexpected referer:\n- want: %v\n-  got: %v",
+					want, referer)
+			}
+			for key, want := range map[string]string{
+				"ping":          "20",
+				"download":      "10499",
+				"upload":        "2000",
+				"serverid":      "1234",
+				"bytesreceived": "13000000",
+				"bytessent":     "2500000",
+				"hash":          "e0c75592e80b49540e7650941c40fcb7",
+			} {
+				if got := form[key]; len(got) != 1 || got[0] != want {
+					t.Errorf("unexpected %s:\n- want: %v\n-  got: %v", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestKilobits(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer *TransferResult
+		want     int
+	}{
+		{name: "no transfer", transfer: nil, want: 0},
+		{name: "rounded down", transfer: &TransferResult{BitsPerSecond: 10499499}, want: 10499},
+		{name: "rounded up", transfer: &TransferResult{BitsPerSecond: 10499500}, want: 10500},
+		{name: "below one kilobit", transfer: &TransferResult{BitsPerSecond: 499}, want: 0},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := kilobits(tc.transfer); got != tc.want {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v",
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestShareContextCancel(t *testing.T) {
+	started := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm() // Read the body to notice the disconnect
+		close(started)
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(&Opts{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("unexpected client error: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	got, err := ShareContext(ctx, c, ts.URL, &Result{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected result %q, error: %v", got, err)
+	}
+}
@@ -0,0 +1,110 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"time"
+)
+
+const probeDownloadImage = "random500x500.jpg" // Image downloaded to estimate line speed
+const probeUploadSize = 250000                 // Size of the payload uploaded to estimate line speed
+
+// Estimates line speed with a short download and upload, and selects payload sizes of the given profile
+// appropriate to that speed according to the configured thresholds.
+// Returns the given profile as is when there are no thresholds configured.
+func (server *Server) SelectPayloadSizes(
+	ctx context.Context,
+	times ConfigTimes,
+	profile *TestProfile) (*TestProfile, error) {
+	if len(times) == 0 {
+		return profile, nil
+	}
+
+	server.client.Log("Estimating line speed...")
+
+	download, err := server.probeDownloadSpeed(ctx)
+	if err != nil {
+		return profile, err
+	}
+	upload, err := server.probeUploadSpeed(ctx)
+	if err != nil {
+		return profile, err
+	}
+
+	return profile.ForSpeed(times, download, upload), nil
+}
+
+// Returns a copy of the profile with payload sizes appropriate to the given line speed in bits per second.
+// The more thresholds the speed exceeds, the larger payloads are selected.
+// The total number of requests is preserved.
+func (profile *TestProfile) ForSpeed(times ConfigTimes, download float64, upload float64) *TestProfile {
+	result := *profile
+	downloadTier, uploadTier := 0, 0
+	for _, threshold := range times {
+		if download > float64(threshold.Download) {
+			downloadTier++
+		}
+		if upload > float64(threshold.Upload) {
+			uploadTier++
+		}
+	}
+	result.DownloadSizes, result.DownloadRepeats =
+		selectSizes(profile.DownloadSizes, profile.DownloadRepeats, downloadTier, len(times))
+	result.UploadSizes, result.UploadRepeats =
+		selectSizes(profile.UploadSizes, profile.UploadRepeats, uploadTier, len(times))
+	return &result
+}
+
+// Selects a half of the given sizes, shifted towards larger ones according to the tier.
+func selectSizes(sizes []int, repeats int, tier int, tiers int) ([]int, int) {
+	if len(sizes) < 2 || tiers == 0 {
+		return sizes, repeats
+	}
+	window := (len(sizes) + 1) / 2
+	start := tier * (len(sizes) - window) / tiers
+	return sizes[start : start+window], int(math.Ceil(float64(repeats*len(sizes)) / float64(window)))
+}
+
+func (server *Server) probeDownloadSpeed(ctx context.Context) (float64, error) {
+	url, err := server.RelativeURL(probeDownloadImage)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := server.client.GetContext(ctx, url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, &HTTPStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+	read, err := io.Copy(ioutil.Discard, resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	return float64(read*8) / time.Since(start).Seconds(), nil
+}
+
+func (server *Server) probeUploadSpeed(ctx context.Context) (float64, error) {
+	start := time.Now()
+	resp, err := server.client.PostContext(
+		ctx,
+		server.URL,
+		"application/x-www-form-urlencoded",
+		io.MultiReader(
+			strings.NewReader("content1="),
+			io.LimitReader(&safeReader{rand.Reader}, probeUploadSize-9)))
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, &HTTPStatusError{URL: server.URL, StatusCode: resp.StatusCode}
+	}
+	return float64(probeUploadSize*8) / time.Since(start).Seconds(), nil
+}
@@ -0,0 +1,82 @@
+package speedtest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForSpeed(t *testing.T) {
+	times := ConfigTimes{{Download: 1000, Upload: 100}, {Download: 2000, Upload: 200}}
+	profile := &TestProfile{
+		DownloadSizes:   []int{350, 500, 750, 1000, 1500},
+		DownloadRepeats: 2,
+		UploadSizes:     []int{250000, 500000},
+		UploadRepeats:   10,
+	}
+
+	tests := []struct {
+		name     string
+		times    ConfigTimes
+		download float64
+		upload   float64
+		want     *TestProfile
+	}{
+		{
+			name:     "no thresholds",
+			times:    ConfigTimes{},
+			download: 3000,
+			upload:   300,
+			want:     profile,
+		},
+		{
+			name:     "slow",
+			times:    times,
+			download: 500,
+			upload:   50,
+			want: &TestProfile{
+				DownloadSizes:   []int{350, 500, 750},
+				DownloadRepeats: 4,
+				UploadSizes:     []int{250000},
+				UploadRepeats:   20,
+			},
+		},
+		{
+			name:     "medium",
+			times:    times,
+			download: 1500,
+			upload:   150,
+			want: &TestProfile{
+				DownloadSizes:   []int{500, 750, 1000},
+				DownloadRepeats: 4,
+				UploadSizes:     []int{250000},
+				UploadRepeats:   20,
+			},
+		},
+		{
+			name:     "fast",
+			times:    times,
+			download: 3000,
+			upload:   300,
+			want: &TestProfile{
+				DownloadSizes:   []int{750, 1000, 1500},
+				DownloadRepeats: 4,
+				UploadSizes:     []int{500000},
+				UploadRepeats:   20,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got := profile.ForSpeed(tc.times, tc.download, tc.upload)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %+v\n-  got: %+v",
+					tc.want, got)
+			}
+			if len(profile.DownloadSizes) != 5 || len(profile.UploadSizes) != 2 {
+				t.Errorf("profile modified: %+v", profile)
+			}
+		})
+	}
+}
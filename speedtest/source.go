@@ -0,0 +1,187 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Source of server list.
+type ServerSource interface {
+	// Loads server list using the given client unless the context is done.
+	LoadServers(ctx context.Context, client Client) (*Servers, error)
+}
+
+// Server list retrieved by HTTP in either speedtest.net XML format, or JSON format.
+// The URL may omit the scheme, e.g. `://www.speedtest.net/speedtest-servers.php`, to respect Secure option.
+type URLServerSource string
+
+// Server list read from local file in either speedtest.net XML format, or JSON format.
+type FileServerSource string
+
+// Server list embedded into the program in either speedtest.net XML format, or JSON format,
+// e.g. with go:embed directive.
+type EmbeddedServerSource struct {
+	Name    string // Displayed in log messages
+	Content []byte
+}
+
+// speedtest.net server lists.
+var DefaultServerSources = []ServerSource{
+	URLServerSource("://www.speedtest.net/speedtest-servers-static.php"),
+	URLServerSource("://c.speedtest.net/speedtest-servers-static.php"),
+	URLServerSource("://www.speedtest.net/speedtest-servers.php"),
+	URLServerSource("://c.speedtest.net/speedtest-servers.php"),
+}
+
+// Returns URL server source if the given location is HTTP(S) URL, or file server source otherwise.
+func ParseServerSource(location string) ServerSource {
+	for _, prefix := range []string{"http://", "https://", "://"} {
+		if strings.HasPrefix(location, prefix) {
+			return URLServerSource(location)
+		}
+	}
+	return FileServerSource(location)
+}
+
+func (source URLServerSource) LoadServers(ctx context.Context, client Client) (*Servers, error) {
+	url := string(source)
+	resp, err := client.GetContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, &HTTPStatusError{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode}
+	}
+	content, err := resp.ReadContent()
+	if err != nil {
+		return nil, err
+	}
+	return parseServers(content)
+}
+
+func (source URLServerSource) String() string {
+	return string(source)
+}
+
+func (source FileServerSource) LoadServers(context.Context, Client) (*Servers, error) {
+	content, err := ioutil.ReadFile(string(source))
+	if err != nil {
+		return nil, err
+	}
+	return parseServers(content)
+}
+
+func (source FileServerSource) String() string {
+	return string(source)
+}
+
+func (source *EmbeddedServerSource) LoadServers(context.Context, Client) (*Servers, error) {
+	return parseServers(source.Content)
+}
+
+func (source *EmbeddedServerSource) String() string {
+	return source.Name
+}
+
+// Parses server list in either speedtest.net XML format, or JSON format.
+func parseServers(content []byte) (*Servers, error) {
+	servers := &Servers{}
+	trimmed := bytes.TrimSpace(content)
+	if !bytes.HasPrefix(trimmed, []byte("[")) && !bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := xml.Unmarshal(content, servers); err != nil {
+			return nil, err
+		}
+		return servers, nil
+	}
+
+	var list []jsonServer
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(content, &list); err != nil {
+			return nil, err
+		}
+	} else {
+		var wrapper struct {
+			Servers []jsonServer `json:"servers"`
+		}
+		if err := json.Unmarshal(content, &wrapper); err != nil {
+			return nil, err
+		}
+		list = wrapper.Servers
+	}
+
+	for _, item := range list {
+		server, err := item.server()
+		if err != nil {
+			return nil, err
+		}
+		servers.List = append(servers.List, server)
+	}
+	return servers, nil
+}
+
+// Server in JSON format. Field names are the same as XML attribute names of speedtest.net server list.
+type jsonServer struct {
+	ID        jsonValue `json:"id"`
+	URL       string    `json:"url"`
+	URL2      string    `json:"url2"`
+	Name      string    `json:"name"`
+	Country   string    `json:"country"`
+	CC        string    `json:"cc"`
+	Sponsor   string    `json:"sponsor"`
+	Host      string    `json:"host"`
+	Latitude  jsonValue `json:"lat"`
+	Longitude jsonValue `json:"lon"`
+}
+
+func (item *jsonServer) server() (*Server, error) {
+	id, err := strconv.ParseUint(string(item.ID), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid server ID: %s", item.ID)
+	}
+	latitude, err := item.Latitude.float()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid latitude of server %d: %s", id, item.Latitude)
+	}
+	longitude, err := item.Longitude.float()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid longitude of server %d: %s", id, item.Longitude)
+	}
+	return &Server{
+		Coordinates: Coordinates{Latitude: latitude, Longitude: longitude},
+		ID:          ServerID(id),
+		URL:         item.URL,
+		URL2:        item.URL2,
+		Name:        item.Name,
+		Country:     item.Country,
+		CC:          item.CC,
+		Sponsor:     item.Sponsor,
+		Host:        item.Host,
+	}, nil
+}
+
+// JSON number, possibly quoted.
+type jsonValue string
+
+func (value *jsonValue) UnmarshalJSON(data []byte) error {
+	if unquoted, err := strconv.Unquote(string(data)); err == nil {
+		*value = jsonValue(unquoted)
+	} else {
+		*value = jsonValue(data)
+	}
+	return nil
+}
+
+func (value jsonValue) float() (float32, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	result, err := strconv.ParseFloat(string(value), 32)
+	return float32(result), err
+}
@@ -0,0 +1,191 @@
+package speedtest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseServerSource(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ServerSource
+	}{
+		{input: "https://registry.example.com/servers.json", want: URLServerSource("https://registry.example.com/servers.json")},
+		{input: "://www.speedtest.net/speedtest-servers.php", want: URLServerSource("://www.speedtest.net/speedtest-servers.php")},
+		{input: "/etc/speedtest/servers.xml", want: FileServerSource("/etc/speedtest/servers.xml")},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.input, func(t *testing.T) {
+			if got := ParseServerSource(tc.input); got != tc.want {
+				t.Errorf("unexpected result:\n- want: %#v\n-  got: %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_parseServers(t *testing.T) {
+	want := []*Server{
+		{
+			Coordinates: Coordinates{Latitude: 52.5, Longitude: 13.25},
+			URL:         "http://speed.example.com:8080/speedtest/upload.php",
+			Name:        "Berlin",
+			Country:     "Germany",
+			CC:          "DE",
+			Sponsor:     "Example",
+			ID:          1234,
+			Host:        "speed.example.com:8080",
+		},
+	}
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{
+			name: "xml",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<settings><servers>
+<server url="http://speed.example.com:8080/speedtest/upload.php" lat="52.5" lon="13.25" name="Berlin"
+ country="Germany" cc="DE" sponsor="Example" id="1234" host="speed.example.com:8080"/>
+</servers></settings>`,
+		},
+		{
+			name: "json array",
+			input: `[{"url": "http://speed.example.com:8080/speedtest/upload.php", "lat": "52.5", "lon": "13.25",
+ "name": "Berlin", "country": "Germany", "cc": "DE", "sponsor": "Example", "id": "1234",
+ "host": "speed.example.com:8080"}]`,
+		},
+		{
+			name: "json object",
+			input: ` {"servers": [{"url": "http://speed.example.com:8080/speedtest/upload.php", "lat": 52.5, "lon": 13.25,
+ "name": "Berlin", "country": "Germany", "cc": "DE", "sponsor": "Example", "id": 1234,
+ "host": "speed.example.com:8080"}]}`,
+		},
+		{
+			name:    "invalid id",
+			input:   `[{"id": "abc"}]`,
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseServers([]byte(tc.input))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got: %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got.List, want) {
+				t.Errorf("unexpected result:\n- want: %+v\n-  got: %+v", want[0], got.List[0])
+			}
+		})
+	}
+}
+
+func TestClient_serverSources(t *testing.T) {
+	config := &Config{Client: ClientConfig{IP: "192.0.2.1"}}
+	defaultServers := &Servers{List: []*Server{
+		{ID: 1, Sponsor: "Default", Coordinates: Coordinates{Latitude: 0, Longitude: 10}},
+		{ID: 2, Sponsor: "Default", Coordinates: Coordinates{Latitude: 0, Longitude: 1}},
+	}}
+	custom := &EmbeddedServerSource{
+		Name: "embedded",
+		Content: []byte(`[{"id": 3, "sponsor": "Custom", "lat": 0, "lon": 5},
+ {"id": 2, "sponsor": "Custom", "lat": 0, "lon": 20}]`),
+	}
+
+	type server struct {
+		ID      ServerID
+		Sponsor string
+		Known   bool
+	}
+	tests := []struct {
+		name    string
+		config  bool
+		opts    Opts
+		want    []server
+		wantErr bool
+	}{
+		{
+			name:   "default only",
+			config: true,
+			want:   []server{{2, "Default", true}, {1, "Default", true}},
+		},
+		{
+			name:   "merged by distance",
+			config: true,
+			opts:   Opts{ServerSources: []ServerSource{custom}},
+			want:   []server{{2, "Default", true}, {3, "Custom", true}, {1, "Default", true}},
+		},
+		{
+			name:   "no default servers",
+			config: true,
+			opts:   Opts{ServerSources: []ServerSource{custom}, NoDefaultServers: true},
+			want:   []server{{3, "Custom", true}, {2, "Custom", true}},
+		},
+		{
+			name: "merged by id without config",
+			opts: Opts{ServerSources: []ServerSource{custom}},
+			want: []server{{1, "Default", false}, {2, "Default", false}, {3, "Custom", false}},
+		},
+		{
+			name:    "default only without config",
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "speedtest-cache")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			opts := tc.opts
+			opts.Quiet = true
+			opts.CacheTTL = time.Hour
+			c, err := NewClient(&opts)
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			c.(*client).cacheDir = dir
+			c.(*client).Transport = roundTripFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("unreachable")
+			})
+			c.(*client).storeCached("servers.json", defaultServers)
+			if tc.config {
+				c.(*client).storeCached(c.(*client).configCacheName(), config)
+			}
+
+			servers, err := c.AllServers()
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got: %v", servers)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []server
+			for _, s := range servers.List {
+				got = append(got, server{s.ID, s.Sponsor, s.HasDistance()})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package speedtest
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Default interval of throughput sampling.
+const DefaultSampleInterval = 100 * time.Millisecond
+
+// Cumulative number of bytes transferred since the test start.
+type Sample struct {
+	Elapsed time.Duration
+	Bytes   int64
+}
+
+// Throughput statistics derived from samples. Rates are in bytes per second.
+type ThroughputStats struct {
+	Mean      float64 // Mean of per-interval rates
+	Peak      float64 // Maximum per-interval rate
+	P10       float64 // 10th percentile of per-interval rates
+	P50       float64 // Median of per-interval rates
+	P90       float64 // 90th percentile of per-interval rates
+	Stability float64 // Coefficient of variation of per-interval rates. The lower, the more stable
+
+	// Steady-state rate. Computed after the ramp-up phase, discarding the fastest and the slowest intervals
+	Stable float64
+	RampUp time.Duration // Duration of the ramp-up phase, e.g. TCP slow start
+}
+
+const (
+	rampUpThreshold   = 0.8 // Ramp-up ends when the rate reaches this fraction of the 90th percentile
+	stableDropFastest = 0.1 // Fraction of the fastest intervals discarded from the stable rate
+	stableDropSlowest = 0.3 // Fraction of the slowest intervals discarded from the stable rate
+)
+
+// Computes transfer rates between consecutive samples, starting from the test start.
+// Returns the rates along with the start times of corresponding intervals.
+func sampleRates(samples []Sample) (rates []float64, starts []time.Duration) {
+	rates = make([]float64, 0, len(samples))
+	starts = make([]time.Duration, 0, len(samples))
+	var prev Sample
+	for _, sample := range samples {
+		if elapsed := sample.Elapsed - prev.Elapsed; elapsed > 0 {
+			rates = append(rates, float64(sample.Bytes - prev.Bytes) / elapsed.Seconds())
+			starts = append(starts, prev.Elapsed)
+		}
+		prev = sample
+	}
+	return rates, starts
+}
+
+// Computes throughput statistics of the given samples.
+func NewThroughputStats(samples []Sample) ThroughputStats {
+	rates, starts := sampleRates(samples)
+	stats := ThroughputStats{}
+	if len(rates) == 0 {
+		return stats
+	}
+
+	ordered := rates
+	rates = make([]float64, len(ordered))
+	copy(rates, ordered)
+	sort.Float64s(rates)
+	stats.Peak = rates[len(rates) - 1]
+	stats.P10 = percentile(rates, 10)
+	stats.P50 = percentile(rates, 50)
+	stats.P90 = percentile(rates, 90)
+
+	var sum float64
+	for _, rate := range rates {
+		sum += rate
+	}
+	stats.Mean = sum / float64(len(rates))
+
+	if stats.Mean > 0 {
+		var variance float64
+		for _, rate := range rates {
+			variance += (rate - stats.Mean) * (rate - stats.Mean)
+		}
+		stats.Stability = math.Sqrt(variance / float64(len(rates))) / stats.Mean
+	}
+
+	stats.Stable, stats.RampUp = stableRate(ordered, starts, stats.P90)
+
+	return stats
+}
+
+// Estimates the steady-state rate from per-interval rates in the order of measurement.
+// Skips the ramp-up intervals, then averages the remaining ones except the fastest and the slowest.
+func stableRate(rates []float64, starts []time.Duration, reference float64) (float64, time.Duration) {
+	rampUp := 0
+	for rampUp < len(rates) && rates[rampUp] < reference * rampUpThreshold {
+		rampUp++
+	}
+	if rampUp == len(rates) {
+		return 0, 0
+	}
+
+	steady := make([]float64, len(rates) - rampUp)
+	copy(steady, rates[rampUp:])
+	sort.Float64s(steady)
+	steady = steady[int(float64(len(steady)) * stableDropSlowest):len(steady) - int(float64(len(steady)) * stableDropFastest)]
+	if len(steady) == 0 {
+		return 0, starts[rampUp]
+	}
+
+	var sum float64
+	for _, rate := range steady {
+		sum += rate
+	}
+
+	return sum / float64(len(steady)), starts[rampUp]
+}
+
+// Returns the nearest-rank percentile of the sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank - 1]
+}
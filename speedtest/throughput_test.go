@@ -0,0 +1,78 @@
+package speedtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewThroughputStats(t *testing.T) {
+	ms := 100 * time.Millisecond
+	tests := []struct {
+		name    string
+		samples []Sample
+		want    ThroughputStats
+	}{
+		{
+			name: "no samples",
+			want: ThroughputStats{},
+		},
+		{
+			name: "constant rate",
+			samples: []Sample{
+				{Elapsed: 1 * ms, Bytes: 100},
+				{Elapsed: 2 * ms, Bytes: 200},
+				{Elapsed: 3 * ms, Bytes: 300},
+			},
+			want: ThroughputStats{Mean: 1000, Peak: 1000, P10: 1000, P50: 1000, P90: 1000, Stable: 1000},
+		},
+		{
+			name: "collapsing rate",
+			samples: []Sample{
+				{Elapsed: 1 * ms, Bytes: 300},
+				{Elapsed: 2 * ms, Bytes: 600},
+				{Elapsed: 3 * ms, Bytes: 600},
+				{Elapsed: 4 * ms, Bytes: 800},
+			},
+			want: ThroughputStats{
+				Mean:      2000,
+				Peak:      3000,
+				P10:       0,
+				P50:       2000,
+				P90:       3000,
+				Stability: 0.6123724356957945,
+				Stable:    8000.0 / 3,
+			},
+		},
+		{
+			name: "slow start",
+			samples: []Sample{
+				{Elapsed: 1 * ms, Bytes: 100},
+				{Elapsed: 2 * ms, Bytes: 400},
+				{Elapsed: 3 * ms, Bytes: 1400},
+				{Elapsed: 4 * ms, Bytes: 2400},
+				{Elapsed: 5 * ms, Bytes: 3400},
+				{Elapsed: 6 * ms, Bytes: 4400},
+			},
+			want: ThroughputStats{
+				Mean:      7333.333333333333,
+				Peak:      10000,
+				P10:       1000,
+				P50:       10000,
+				P90:       10000,
+				Stability: 0.5202510519208908,
+				Stable:    10000,
+				RampUp:    2 * ms,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := NewThroughputStats(tc.samples), tc.want; got != want {
+				t.Fatalf("unexpected result:\n- want: %+v\n-  got: %+v",
+					want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package speedtest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Default maximum number of simultaneous streams used by adaptive stream concurrency.
+const DefaultMaxStreams = 32
+
+const adaptiveInterval = 500 * time.Millisecond
+const adaptiveGain = 0.1 // Minimal throughput gain required to add more streams
+
+// Single download or upload request to perform.
+type transferTask struct {
+	server *Server
+	url    string
+	size   int
+}
+
+// Outcome of a single download or upload request.
+type transfer struct {
+	server  *Server
+	size    int
+	bytes   int64
+	started bool // Whether the request has been started before the test deadline
+	timing  RequestTiming
+	err     error
+}
+
+// Throughput test configuration.
+type transferConfig struct {
+	phase      Phase
+	tasks      []transferTask
+	duration   time.Duration // Maximum test duration
+	streams    int           // Initial number of simultaneous streams
+	maxStreams int           // Streams are added adaptively up to this number when it exceeds the initial one
+	bufferSize int           // Download buffer size
+}
+
+func (config *transferConfig) adaptive() bool {
+	return config.maxStreams > config.streams
+}
+
+// Performs the configured transfer tasks.
+// With fixed stream concurrency each task is performed once, with at most the configured number of simultaneous streams.
+// With adaptive stream concurrency the tasks are repeated until the test duration elapses,
+// and streams are added while the throughput keeps rising.
+// Progress is reported to the observer specified in client options.
+// When the context is done, the tasks not started yet are skipped, and the running ones are interrupted.
+func (client *client) measure(
+	ctx context.Context,
+	config *transferConfig,
+	transferFile func(ctx context.Context, task transferTask, run *transferRun, ret chan transfer)) *Measurement {
+
+	limiter := newStreamLimiter(config.streams)
+	resultChan := make(chan transfer, config.maxStreams)
+	run := &transferRun{config: config, start: time.Now()}
+	wireStart := client.wire.phaseBytes(config.phase)
+	stopMonitor := run.monitor(client.opts.SampleInterval, client.opts.Progress)
+	stopAdapting := func() {}
+	if config.adaptive() {
+		stopAdapting = run.adaptStreams(limiter, config.streams, config.maxStreams)
+	}
+
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < len(config.tasks) || config.adaptive() && len(config.tasks) != 0; i++ {
+			task := config.tasks[i % len(config.tasks)]
+			limiter.acquire()
+			if i >= len(config.tasks) && (run.expired() || ctx.Err() != nil) {
+				limiter.release()
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				transferFile(ctx, task, run, resultChan)
+				limiter.release()
+			}()
+		}
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	measurement := &Measurement{}
+
+	for result := range resultChan {
+		measurement.add(result)
+	}
+
+	measurement.Duration = time.Since(run.start)
+	measurement.WireBytes = client.wire.phaseBytes(config.phase) - wireStart
+	measurement.Err = ctx.Err()
+	stopAdapting()
+	measurement.Streams = limiter.maxLimit()
+	measurement.Samples = stopMonitor()
+	measurement.Throughput = NewThroughputStats(measurement.Samples)
+
+	return measurement
+}
+
+// Adds streams while the throughput keeps rising, until the returned function is called.
+func (run *transferRun) adaptStreams(limiter *streamLimiter, streams int, maxStreams int) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(adaptiveInterval)
+		defer ticker.Stop()
+		last := run.progress(nil)
+		var lastRate float64
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			progress := run.progress(&last)
+			last = progress
+			next := adaptedStreams(streams, maxStreams, lastRate, progress.Rate)
+			if next == streams {
+				return // Throughput does not rise any more, or the maximum reached
+			}
+			lastRate = progress.Rate
+			streams = next
+			limiter.setLimit(streams)
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// Returns the number of streams to use after the throughput changed from the last rate to the given one.
+// Doubles the streams up to the maximum while the throughput rises by more than the adaptive gain.
+func adaptedStreams(streams int, maxStreams int, lastRate float64, rate float64) int {
+	if rate <= lastRate * (1 + adaptiveGain) {
+		return streams
+	}
+	streams *= 2
+	if streams > maxStreams {
+		return maxStreams
+	}
+	return streams
+}
+
+// Limits the number of simultaneous streams.
+type streamLimiter struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	max    int // Maximum limit set
+	active int
+}
+
+func newStreamLimiter(limit int) *streamLimiter {
+	limiter := &streamLimiter{limit: limit, max: limit}
+	limiter.cond = sync.NewCond(&limiter.mutex)
+	return limiter
+}
+
+func (limiter *streamLimiter) acquire() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	for limiter.active >= limiter.limit {
+		limiter.cond.Wait()
+	}
+	limiter.active++
+}
+
+func (limiter *streamLimiter) release() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.active--
+	limiter.cond.Signal()
+}
+
+func (limiter *streamLimiter) setLimit(limit int) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.limit = limit
+	if limit > limiter.max {
+		limiter.max = limit
+	}
+	limiter.cond.Broadcast()
+}
+
+func (limiter *streamLimiter) maxLimit() int {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return limiter.max
+}
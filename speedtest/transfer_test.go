@@ -0,0 +1,131 @@
+package speedtest
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAdaptedStreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		streams    int
+		maxStreams int
+		lastRate   float64
+		rate       float64
+		want       int
+	}{
+		{name: "first sample", streams: 6, maxStreams: 32, lastRate: 0, rate: 1000, want: 12},
+		{name: "no throughput", streams: 6, maxStreams: 32, lastRate: 0, rate: 0, want: 6},
+		{name: "rising", streams: 12, maxStreams: 32, lastRate: 1000, rate: 1200, want: 24},
+		{name: "gain threshold", streams: 12, maxStreams: 32, lastRate: 1000, rate: 1100, want: 12},
+		{name: "below gain threshold", streams: 12, maxStreams: 32, lastRate: 1000, rate: 1050, want: 12},
+		{name: "falling", streams: 12, maxStreams: 32, lastRate: 1000, rate: 900, want: 12},
+		{name: "capped", streams: 24, maxStreams: 32, lastRate: 1000, rate: 2000, want: 32},
+		{name: "maximum reached", streams: 32, maxStreams: 32, lastRate: 1000, rate: 2000, want: 32},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := adaptedStreams(tc.streams, tc.maxStreams, tc.lastRate, tc.rate); got != tc.want {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v",
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestAdaptStreams(t *testing.T) {
+	tests := []struct {
+		name       string
+		streams    int
+		maxStreams int
+		rising     bool
+		want       int
+	}{
+		{name: "ramp-up to maximum", streams: 2, maxStreams: 6, rising: true, want: 6},
+		{name: "steady throughput", streams: 2, maxStreams: 6, rising: false, want: 4}, // Added after the first interval
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run := &transferRun{config: &transferConfig{duration: time.Minute}, start: time.Now()}
+			limiter := newStreamLimiter(tc.streams)
+
+			done := make(chan struct{})
+			go func() {
+				var bytes int64 = 1000
+				ticker := time.NewTicker(10 * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-ticker.C:
+					}
+					if tc.rising {
+						bytes += 1000
+					}
+					run.addBytes(bytes)
+				}
+			}()
+
+			stop := run.adaptStreams(limiter, tc.streams, tc.maxStreams)
+			time.Sleep(4 * adaptiveInterval)
+			stop()
+			close(done)
+
+			if got := limiter.maxLimit(); got != tc.want {
+				t.Errorf("unexpected streams:\n- want: %v\n-  got: %v",
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamLimiter(t *testing.T) {
+	limiter := newStreamLimiter(2)
+	var active, peak int32
+	var wg sync.WaitGroup
+
+	stream := func() {
+		defer wg.Done()
+		limiter.acquire()
+		defer limiter.release()
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+	}
+	run := func(streams int) int32 {
+		atomic.StoreInt32(&peak, 0)
+		for i := 0; i < streams; i++ {
+			wg.Add(1)
+			go stream()
+		}
+		wg.Wait()
+		return atomic.LoadInt32(&peak)
+	}
+
+	if got := run(8); got != 2 {
+		t.Errorf("unexpected simultaneous streams:\n- want: %v\n-  got: %v", 2, got)
+	}
+
+	limiter.setLimit(4)
+	if got := run(8); got != 4 {
+		t.Errorf("unexpected simultaneous streams after limit raised:\n- want: %v\n-  got: %v", 4, got)
+	}
+
+	limiter.setLimit(3)
+	if got := limiter.maxLimit(); got != 4 {
+		t.Errorf("unexpected maximum limit:\n- want: %v\n-  got: %v", 4, got)
+	}
+}
@@ -1,32 +1,15 @@
 package speedtest
 
 import (
-	"time"
-	"os"
+	"context"
+	"errors"
 	"log"
 	"io"
 	"strings"
 	"crypto/rand"
+	"sync"
 )
 
-const maxUploadDuration = maxDownloadDuration
-const uploadStreamLimit = downloadStreamLimit
-const uploadRepeats = downloadRepeats
-
-var uploadSizes []int
-
-func init() {
-
-	var uploadSizeSizes = []int{int(1000 * 1000 / 4), int(1000 * 1000 / 2)}
-
-	uploadSizes = make([]int, len(uploadSizeSizes) * 25)
-	for _, size := range uploadSizeSizes {
-		for i := 0; i < 25; i++ {
-			uploadSizes[i] = size
-		}
-	}
-}
-
 const safeChars = "0123456789abcdefghijklmnopqrstuv"
 
 type safeReader struct {
@@ -43,75 +26,131 @@ func (r safeReader) Read(p []byte) (n int, err error) {
 	return n, err
 }
 
-func (client *client) uploadFile(url string, start time.Time, size int, ret chan int) {
-	totalWrote := 0
+// Returned by upload body reader once the test deadline is reached, in order to abort the request.
+var uploadDeadlineError error = errors.New("Upload test deadline reached")
+
+// Counts upload request body bytes as they are consumed by the transport until the test deadline.
+type uploadCounter struct {
+	in      io.Reader
+	run     *transferRun
+	mutex   sync.Mutex
+	bytes   int64
+	expired bool // Whether the body reading has been aborted at the test deadline
+	closed  bool // Whether the request is complete
+}
+
+func (counter *uploadCounter) Read(p []byte) (n int, err error) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	if counter.closed {
+		return 0, uploadDeadlineError
+	}
+	if counter.run.expired() {
+		counter.expired = true
+		return 0, uploadDeadlineError
+	}
+
+	n, err = counter.in.Read(p)
+	counter.bytes += int64(n)
+	counter.run.addBytes(int64(n))
+
+	return n, err
+}
+
+// Stops counting, as the transport may still be reading the body after the response is received.
+// Returns the number of bytes counted and whether the upload has been aborted at the test deadline.
+func (counter *uploadCounter) close() (int64, bool) {
+	counter.mutex.Lock()
+	defer counter.mutex.Unlock()
+
+	counter.closed = true
+
+	return counter.bytes, counter.expired
+}
+
+func (client *client) uploadFile(
+	ctx context.Context,
+	task transferTask,
+	run *transferRun,
+	ret chan transfer) {
+	result := transfer{server: task.server, size: task.size}
 	defer func() {
-		ret <- totalWrote
+		ret <- result
 	}()
 
-	if (time.Since(start) > maxUploadDuration) {
+	if (run.expired() || ctx.Err() != nil) {
 		return;
 	}
-	if !client.opts.Quiet {
-		os.Stdout.WriteString(".")
-		os.Stdout.Sync()
-	}
 
-	resp, err := client.Post(
-		url,
-		"application/x-www-form-urlencoded",
-		io.MultiReader(
+	result.started = true
+	run.startRequest()
+
+	counter := &uploadCounter{
+		in: io.MultiReader(
 			strings.NewReader("content1="),
-			io.LimitReader(&safeReader{rand.Reader}, int64(size - 9))))
+			io.LimitReader(&safeReader{rand.Reader}, int64(task.size - 9))),
+		run: run,
+	}
+	traceCtx, timing := traceRequest(ctx)
+	resp, err := client.PostContext(traceCtx, task.url, "application/x-www-form-urlencoded", counter)
+	bytes, expired := counter.close()
+	result.bytes = bytes
+	result.timing = timing()
 	if err != nil {
-		log.Printf("[%s] Upload failed: %v\n", url, err)
+		if ctx.Err() != nil {
+			result.err = ctx.Err()
+		} else if !expired {
+			log.Printf("[%s] Upload failed: %v\n", task.url, err)
+			result.err = err
+		}
 		return;
 	}
 
-	totalWrote = size
-
 	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		result.err = &HTTPStatusError{URL: task.url, StatusCode: resp.StatusCode}
+		log.Println(result.err)
+	}
 }
 
+// Measures upload speed of the server in bytes per second.
 func (server *Server) UploadSpeed() int {
-	client := server.client.(*client)
-	if !client.opts.Quiet {
-		os.Stdout.WriteString("Testing upload speed: ")
-		os.Stdout.Sync()
-	}
+	return server.Upload().Speed()
+}
 
-	starterChan := make(chan int, uploadStreamLimit)
-	uploads := uploadRepeats * len(uploadSizes)
-	resultChan := make(chan int, uploadStreamLimit)
-	start := time.Now()
-
-	go func() {
-		for _, size := range uploadSizes {
-			size := size // local copy to avoid the data race.
-			for i := 0; i < uploadRepeats; i++ {
-				url := server.URL
-				starterChan <- 1
-				go func() {
-					client.uploadFile(url, start, size, resultChan)
-					<-starterChan
-				}()
-			}
-		}
-		close(starterChan)
-	}()
+// Performs an upload test against the server.
+func (server *Server) Upload() *Measurement {
+	return server.UploadContext(context.Background())
+}
 
-	var totalSize int64 = 0;
+// Performs an upload test against the server unless the context is done.
+// Uses the test profile specified in client options.
+// When interrupted, returns the partial measurement with the context error.
+func (server *Server) UploadContext(ctx context.Context) *Measurement {
+	return server.UploadProfile(ctx, server.client.(*client).profile())
+}
 
-	for i := 0; i < uploads; i++ {
-		totalSize += int64(<-resultChan)
-	}
+// Performs an upload test against the server with the given test profile unless the context is done.
+// When interrupted, returns the partial measurement with the context error.
+func (server *Server) UploadProfile(ctx context.Context, profile *TestProfile) *Measurement {
+	client := server.client.(*client)
+	tasks := server.uploadTasks(profile)
 
-	if !client.opts.Quiet {
-		os.Stdout.WriteString("\n")
-		os.Stdout.Sync()
-	}
+	stopProbing := server.probeLoadedLatency(ctx)
+	measurement := client.measure(ctx, profile.transferConfig(PhaseUpload, tasks), client.uploadFile)
+	measurement.LoadedLatency = stopProbing()
 
-	duration := time.Since(start);
+	return measurement
+}
 
-	return int(totalSize * int64(time.Second) / int64(duration))
+func (server *Server) uploadTasks(profile *TestProfile) []transferTask {
+	tasks := make([]transferTask, 0, profile.UploadRepeats * len(profile.UploadSizes))
+	for _, size := range profile.UploadSizes {
+		for i := 0; i < profile.UploadRepeats; i++ {
+			tasks = append(tasks, transferTask{server: server, url: server.URL, size: size})
+		}
+	}
+	return tasks
 }
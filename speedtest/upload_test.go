@@ -1,6 +1,9 @@
 package speedtest
 
 import (
+	"io"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,7 +28,10 @@ func TestUpload(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			// set timeout to avoid the longer tests.
 			tc.opts.Timeout = 10 * time.Second
-			c := NewClient(&tc.opts)
+			c, err := NewClient(&tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
 			if _, err := c.Config(); err != nil {
 				t.Fatalf("unexpected config error: %v", err)
 			}
@@ -42,3 +48,42 @@ func TestUpload(t *testing.T) {
 		})
 	}
 }
+
+func TestUploadCounter(t *testing.T) {
+	tests := []struct {
+		name        string
+		duration    time.Duration
+		wantBytes   int64
+		wantExpired bool
+	}{
+		{
+			name:      "before deadline",
+			duration:  time.Hour,
+			wantBytes: 5,
+		},
+		{
+			name:        "after deadline",
+			duration:    -time.Second,
+			wantExpired: true,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run := &transferRun{config: &transferConfig{duration: tc.duration}, start: time.Now()}
+			counter := &uploadCounter{in: strings.NewReader("12345"), run: run}
+			_, err := io.Copy(ioutil.Discard, counter)
+			if tc.wantExpired && err != uploadDeadlineError {
+				t.Errorf("unexpected error: %v", err)
+			}
+			bytes, expired := counter.close()
+			if bytes != tc.wantBytes || run.bytes != tc.wantBytes || expired != tc.wantExpired {
+				t.Errorf("unexpected result:\n- want: %v %v\n-  got: %v %v", tc.wantBytes, tc.wantExpired, bytes, expired)
+			}
+			if n, err := counter.Read(make([]byte, 1)); n != 0 || err != uploadDeadlineError {
+				t.Errorf("unexpected read after close: %d, %v", n, err)
+			}
+		})
+	}
+}
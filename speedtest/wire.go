@@ -0,0 +1,58 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+)
+
+// Counts raw bytes transferred over network connections, including HTTP and TLS framing.
+type wireCounter struct {
+	read    int64 // Updated atomically
+	written int64 // Updated atomically
+}
+
+// Wraps the dial function to count bytes transferred over each dialed connection.
+func (counter *wireCounter) dialer(
+	dial func(ctx context.Context, network string, address string) (net.Conn, error),
+) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &wireConn{Conn: conn, counter: counter}, nil
+	}
+}
+
+// Returns the number of bytes read and written so far.
+func (counter *wireCounter) load() (read int64, written int64) {
+	return atomic.LoadInt64(&counter.read), atomic.LoadInt64(&counter.written)
+}
+
+// Returns the number of bytes transferred so far in the direction of the given test phase.
+func (counter *wireCounter) phaseBytes(phase Phase) int64 {
+	read, written := counter.load()
+	if phase == PhaseUpload {
+		return written
+	}
+	return read
+}
+
+// Network connection counting bytes read and written.
+type wireConn struct {
+	net.Conn
+	counter *wireCounter
+}
+
+func (conn *wireConn) Read(b []byte) (int, error) {
+	n, err := conn.Conn.Read(b)
+	atomic.AddInt64(&conn.counter.read, int64(n))
+	return n, err
+}
+
+func (conn *wireConn) Write(b []byte) (int, error) {
+	n, err := conn.Conn.Write(b)
+	atomic.AddInt64(&conn.counter.written, int64(n))
+	return n, err
+}
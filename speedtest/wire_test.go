@@ -0,0 +1,86 @@
+package speedtest
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWireCounter(t *testing.T) {
+	counter := &wireCounter{}
+	server, peer := net.Pipe()
+	defer peer.Close()
+	dial := counter.dialer(func(context.Context, string, string) (net.Conn, error) {
+		return server, nil
+	})
+	conn, err := dial(context.Background(), "tcp", "localhost:80")
+	if err != nil {
+		t.Fatalf("unexpected dial error: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 3)
+		io.ReadFull(peer, buf)
+		peer.Write([]byte("hello"))
+	}()
+	if _, err := conn.Write([]byte("abc")); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 5)); err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+
+	if read, written := counter.load(); read != 5 || written != 3 {
+		t.Errorf("unexpected result:\n- want: %v %v\n-  got: %v %v", 5, 3, read, written)
+	}
+	if got := counter.phaseBytes(PhaseUpload); got != 3 {
+		t.Errorf("unexpected upload bytes: %d", got)
+	}
+}
+
+func TestWireCounterClients(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test=test"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		get     func(c *client, ctx context.Context, url string) (*Response, error)
+		counted bool
+	}{
+		{name: "test request", get: (*client).GetContext, counted: true},
+		{name: "latency under load probe", get: (*client).probeGet, counted: false},
+		{name: "cold latency probe", get: (*client).coldGet, counted: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewClient(&Opts{Timeout: 10 * time.Second})
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			client := c.(*client)
+
+			resp, err := tc.get(client, context.Background(), ts.URL+"/latency.txt")
+			if err != nil {
+				t.Fatalf("unexpected request error: %v", err)
+			}
+			if _, err := resp.ReadContent(); err != nil {
+				t.Fatalf("unexpected read error: %v", err)
+			}
+
+			read, written := client.wire.load()
+			if counted := read != 0 || written != 0; counted != tc.counted {
+				t.Errorf("unexpected result:\n- want: counted %v\n-  got: %v read, %v written",
+					tc.counted, read, written)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/surol/speedtest-cli/speedtest"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "generic error", err: errors.New("failed"), want: exitFailure},
+		{name: "interrupted", err: context.Canceled, want: exitInterrupted},
+		{name: "wrapped interruption", err: fmt.Errorf("Failed to load server list: %w", context.Canceled), want: exitInterrupted},
+		{name: "bind address", err: &speedtest.BindAddressError{Address: "invalid"}, want: exitUsage},
+		{name: "usage", err: usageError("invalid options"), want: exitUsage},
+		{name: "config", err: &speedtest.ConfigError{Err: errors.New("failed")}, want: exitConfig},
+		{name: "no servers", err: speedtest.NoServersError, want: exitNoServers},
+		{name: "wrapped no servers", err: fmt.Errorf("Failed to load server list: %w", speedtest.NoServersError), want: exitNoServers},
+		{name: "server not found", err: &speedtest.ServerNotFoundError{ID: 1234}, want: exitNoServers},
+		{name: "server URL", err: &speedtest.ServerURLError{URL: "invalid", Err: errors.New("failed")}, want: exitServerURL},
+		{name: "HTTP status", err: &speedtest.HTTPStatusError{URL: "http://example.com", StatusCode: 404}, want: exitHTTPStatus},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exitCode(tc.err); got != tc.want {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v",
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConflictingOptions(t *testing.T) {
+	matrix := func(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) error {
+		_, err := runMatrix(ctx, opts, client)
+		return err
+	}
+	parallel := func(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) error {
+		_, err := runParallel(ctx, opts, client)
+		return err
+	}
+	check := func(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) error {
+		return checkOptions(opts)
+	}
+
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, opts *speedtest.Opts, client speedtest.Client) error
+		opts *speedtest.Opts
+	}{
+		{name: "matrix with mini server", run: matrix, opts: &speedtest.Opts{Top: 3, Mini: "http://localhost/speedtest"}},
+		{name: "matrix with share", run: matrix, opts: &speedtest.Opts{Servers: []speedtest.ServerID{1, 2}, Share: true}},
+		{name: "parallel with server", run: parallel, opts: &speedtest.Opts{Parallel: 2, Server: 1234}},
+		{name: "parallel with mini server", run: parallel, opts: &speedtest.Opts{Parallel: 2, Mini: "http://localhost/speedtest"}},
+		{name: "parallel with share", run: parallel, opts: &speedtest.Opts{Parallel: 2, Share: true}},
+		{name: "parallel with bufferbloat", run: parallel, opts: &speedtest.Opts{Parallel: 2, Bufferbloat: true}},
+		{name: "parallel with top", run: check, opts: &speedtest.Opts{Parallel: 2, Top: 3}},
+		{name: "parallel with multiple servers", run: check, opts: &speedtest.Opts{Parallel: 2, Servers: []speedtest.ServerID{1, 2}}},
+		{name: "listen with parallel", run: check, opts: &speedtest.Opts{Listen: ":9696", Parallel: 2}},
+		{name: "listen with top", run: check, opts: &speedtest.Opts{Listen: ":9696", Top: 3}},
+		{name: "listen with multiple servers", run: check, opts: &speedtest.Opts{Listen: ":9696", Servers: []speedtest.ServerID{1, 2}}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.run(context.Background(), tc.opts, nil)
+			if got := exitCode(err); got != exitUsage {
+				t.Errorf("unexpected exit code of %v:\n- want: %v\n-  got: %v",
+					err, exitUsage, got)
+			}
+		})
+	}
+}
+
+func TestExtraLatencyModes(t *testing.T) {
+	warm, cold, connect := speedtest.LatencyWarm, speedtest.LatencyCold, speedtest.LatencyConnect
+
+	tests := []struct {
+		name string
+		opts *speedtest.Opts
+		want []speedtest.LatencyMode
+	}{
+		{name: "default", opts: &speedtest.Opts{}},
+		{name: "single mode", opts: &speedtest.Opts{LatencyModes: []speedtest.LatencyMode{cold}}},
+		{
+			name: "multiple modes",
+			opts: &speedtest.Opts{LatencyModes: []speedtest.LatencyMode{cold, connect}},
+			want: []speedtest.LatencyMode{connect},
+		},
+		{name: "bufferbloat", opts: &speedtest.Opts{Bufferbloat: true}},
+		{
+			name: "bufferbloat with warm mode",
+			opts: &speedtest.Opts{Bufferbloat: true, LatencyModes: []speedtest.LatencyMode{warm, cold}},
+			want: []speedtest.LatencyMode{cold},
+		},
+		{
+			name: "bufferbloat with other warm mode",
+			opts: &speedtest.Opts{Bufferbloat: true, LatencyModes: []speedtest.LatencyMode{cold, warm}},
+			want: []speedtest.LatencyMode{warm},
+		},
+		{
+			name: "bufferbloat without warm mode",
+			opts: &speedtest.Opts{Bufferbloat: true, LatencyModes: []speedtest.LatencyMode{cold, connect}},
+			want: []speedtest.LatencyMode{connect, warm},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if got := extraLatencyModes(tc.opts); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected result:\n- want: %v\n-  got: %v",
+					tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *speedtest.Opts
+	}{
+		{name: "default", opts: &speedtest.Opts{}},
+		{name: "parallel", opts: &speedtest.Opts{Parallel: 2}},
+		{name: "single parallel server with top", opts: &speedtest.Opts{Parallel: 1, Top: 3}},
+		{name: "top", opts: &speedtest.Opts{Top: 3}},
+		{name: "listen", opts: &speedtest.Opts{Listen: ":9696", Servers: []speedtest.ServerID{1}, Server: 1}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			if err := checkOptions(tc.opts); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}